@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"io"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand run with its arguments.
+type command struct {
+	usage string
+	run   func(args []string, stdout, stderr io.Writer) error
+}
+
+// commands are the subcommands by name.
+var commands = map[string]command{
+	"ranges": {usage: "ranges [-data files] [-family 4|6] [-json] field=value|field^=value|field~=regexp ...", run: runRanges},
+	"export": {usage: "export -format cidr|haproxy|ipset|iptables|nftables|nginx [-data files] [-family 4|6] " +
+		"[-name name] [-target target] [-value value] [-default value] filter ...", run: runExport},
+	"diff":  {usage: "diff [-json] [-limit n] old new", run: runDiff},
+	"stats": {usage: "stats [-data files] [-json] [-top n]", run: runStats},
+	"import": {usage: "import -format xdb|geolite2|ip2location -out file [-locations file] " +
+		"[-columns names] [-fields column=field,...] source", run: runImport},
+	"to-mmdb":   {usage: "to-mmdb [-data files] [-out file] [-type type]", run: runToMMDB},
+	"from-mmdb": {usage: "from-mmdb -in file [-out dir] [-lang lang]", run: runFromMMDB},
+}
+
+// defaultDataFiles are the data files loaded by the server.
+const defaultDataFiles = "data/ipv4.dat,data/ipv6.dat"
+
+// Run runs the subcommand in args and returns the exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], stdout, stderr); err != nil {
+		if err != flag.ErrHelp {
+			_, _ = fmt.Fprintf(stderr, "%s error, %s\n", args[0], err)
+		}
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	_, _ = fmt.Fprintln(w, "usage:")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+}
+
+// newFlagSet returns a flag set writing its errors to stderr.
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+// splitList splits the comma separated list and drops the empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadClient loads the comma separated data files into a new client.
+func loadClient(files string) (*ipcity.Client, error) {
+	client := ipcity.NewClient()
+	for _, filename := range splitList(files) {
+		if err := client.Load(filename); err != nil {
+			return nil, fmt.Errorf("load %s error, %s", filename, err)
+		}
+	}
+	return client, nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestStore writes an IPv4 store with Guangzhou at 1.0.0.0/8 and returns its path.
+func writeTestStore(t *testing.T) string {
+	t.Helper()
+	store := provider.NewStore().
+		WithHeader(provider.NewHeader(provider.DataVersionLatest, provider.DataModeIPv4)).
+		WithMetaTable([]*provider.Meta{
+			provider.NewMeta(),
+			provider.NewMeta().WithCountry("中国").WithProvince("广东").WithCity("广州").WithISP("电信"),
+		}).
+		WithEntityList([]*provider.Entity{
+			provider.NewEntity(0x00000000, 0),
+			provider.NewEntity(0x01000000, 1),
+			provider.NewEntity(0x02000000, 0),
+		})
+	data, err := store.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "ipv4.dat")
+	if err = os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestRun(t *testing.T) {
+	data := writeTestStore(t)
+	dir := t.TempDir()
+	mmdbFile := filepath.Join(dir, "ipcity.mmdb")
+	imported := filepath.Join(dir, "imported.dat")
+	csvFile := filepath.Join(dir, "ip2location.csv")
+	csv := "\"16777216\",\"16777471\",\"CN\",\"China\",\"Guangdong\",\"Guangzhou\"\n"
+	if err := os.WriteFile(csvFile, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"ranges", "-data", data, "city=广州"}, 0, "1.0.0.0/8\n"},
+		{[]string{"ranges", "-data", data, "-json", "isp^=电"}, 0, `{"ipv4":["1.0.0.0/8"],"ipv6":[]}` + "\n"},
+		{[]string{"ranges", "-data", data, "-family", "6", "city=广州"}, 0, ""},
+		{[]string{"ranges", "-data", data, "city"}, 1, ""},
+		{[]string{"export", "-data", data, "-format", "haproxy", "-value", "gz", "city=广州"}, 0, "1.0.0.0/8 gz\n"},
+		{[]string{"export", "-data", data, "-format", "unknown", "city=广州"}, 1, ""},
+		{[]string{"to-mmdb", "-data", data, "-out", mmdbFile}, 0, ""},
+		{[]string{"from-mmdb", "-in", mmdbFile, "-out", dir}, 0, filepath.Join(dir, "ipv4.dat") + "\n"},
+		{[]string{"ranges", "-data", filepath.Join(dir, "ipv4.dat"), "city=广州"}, 0, "1.0.0.0/8\n"},
+		{[]string{"from-mmdb", "-out", dir}, 1, ""},
+		{[]string{"import", "-format", "ip2location", "-fields", "city=city_name", "-out", imported, csvFile}, 0,
+			imported + " IPv4 3 entities 2 meta rows\n"},
+		{[]string{"ranges", "-data", imported, "city=Guangzhou"}, 0, "1.0.0.0/24\n"},
+		{[]string{"import", "-format", "unknown", "-out", imported, csvFile}, 1, ""},
+		{[]string{"diff", data, imported}, 0, "added 0, removed 1, reassigned 1 ranges, 16777216 addresses\n" +
+			"~ 1.0.0.0-1.0.0.255 country 中国 -> , province 广东 -> , city 广州 -> Guangzhou, isp 电信 -> \n" +
+			"- 1.0.1.0-1.255.255.255 country=中国 province=广东 city=广州 isp=电信\n" +
+			"countries:\n  中国 2 ranges, 16777216 addresses\n  - 1 ranges, 256 addresses\n" +
+			"isps:\n  电信 2 ranges, 16777216 addresses\n  - 1 ranges, 256 addresses\n"},
+		{[]string{"stats", "-data", data}, 0, "IPv4 covered 1 ranges, 16777216 addresses, " +
+			"gaps 2 ranges, 4278190080 addresses, space 4294967296 addresses\n" +
+			"countries:\n  中国 1 ranges, 16777216 addresses\nprovinces:\n  广东 1 ranges, 16777216 addresses\n" +
+			"isps:\n  电信 1 ranges, 16777216 addresses\nsizes:\n  /8 1 ranges\nunreferenced meta rows: 0\n"},
+		{[]string{"stats", "-data", filepath.Join(dir, "missing.dat")}, 1, ""},
+		{[]string{"diff", data}, 1, ""},
+		{[]string{"unknown"}, 2, ""},
+	} {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if code := Run(c.args, stdout, stderr); code != c.code || stdout.String() != c.want {
+			t.Errorf("unexpected %s result %d %q, want %d %q, stderr %s",
+				strings.Join(c.args, " "), code, stdout, c.code, c.want, stderr)
+		}
+	}
+}
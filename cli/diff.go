@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"strings"
+)
+
+func runDiff(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("diff", stderr)
+	asJSON := flags.Bool("json", false, "print the diff as json")
+	limit := flags.Int("limit", 0, "print at most the count of changes, all if 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("an old and a new data file are required")
+	}
+	old, err := loadStore(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	updated, err := loadStore(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	diff, err := provider.Diff(old, updated)
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && len(diff.Changes) > *limit {
+		diff.Changes = diff.Changes[:*limit]
+	}
+	if *asJSON {
+		return json.NewEncoder(stdout).Encode(diff)
+	}
+	return printDiff(stdout, diff)
+}
+
+// loadStore loads the data file of a single store.
+func loadStore(filename string) (*provider.Store, error) {
+	client, err := loadClient(filename)
+	if err != nil {
+		return nil, err
+	}
+	return client.Stores()[0], nil
+}
+
+func printDiff(w io.Writer, diff *provider.StoreDiff) error {
+	writer := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(writer, "added %d, removed %d, reassigned %d ranges, %s addresses\n",
+		diff.Added, diff.Removed, diff.Reassigned, diff.Addresses)
+	for _, change := range diff.Changes {
+		switch change.Type {
+		case provider.ChangeAdded:
+			_, _ = fmt.Fprintf(writer, "+ %s %s\n", change.Range(), metaText(change.New))
+		case provider.ChangeRemoved:
+			_, _ = fmt.Fprintf(writer, "- %s %s\n", change.Range(), metaText(change.Old))
+		default:
+			items := make([]string, 0, len(change.Fields))
+			for _, field := range change.Fields {
+				items = append(items, fmt.Sprintf("%s %v -> %v", field.Name, field.Old, field.New))
+			}
+			_, _ = fmt.Fprintf(writer, "~ %s %s\n", change.Range(), strings.Join(items, ", "))
+		}
+	}
+	for _, group := range []struct {
+		name   string
+		counts []provider.AffectedCount
+	}{
+		{"countries", diff.Countries},
+		{"isps", diff.ISPs},
+	} {
+		_, _ = fmt.Fprintf(writer, "%s:\n", group.name)
+		for _, c := range group.counts {
+			name := c.Name
+			if name == "" {
+				name = "-"
+			}
+			_, _ = fmt.Fprintf(writer, "  %s %d ranges, %s addresses\n", name, c.Ranges, c.Addresses)
+		}
+	}
+	return writer.Flush()
+}
+
+// metaText returns the non-zero fields of the meta as name=value items.
+func metaText(meta *provider.Meta) string {
+	var items []string
+	for _, field := range meta.Fields() {
+		if v := fmt.Sprint(field.Value); v != "" && v != "0" {
+			items = append(items, field.Name+"="+v)
+		}
+	}
+	return strings.Join(items, " ")
+}
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"io"
+)
+
+func runExport(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("export", stderr)
+	data := flags.String("data", defaultDataFiles, "comma separated data files")
+	family := flags.String("family", "", "address family, 4 or 6, both if empty")
+	format := flags.String("format", string(ipcity.ExportCIDR), "config format")
+	options := ipcity.ExportOptions{}
+	flags.StringVar(&options.Name, "name", "", "set, table, chain or geo variable name")
+	flags.StringVar(&options.Target, "target", "", "iptables rule target")
+	flags.StringVar(&options.Value, "value", "", "nginx geo and HAProxy map value")
+	flags.StringVar(&options.Default, "default", "", "nginx geo default value")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	list, err := findRanges(*data, *family, flags.Args())
+	if err != nil {
+		return err
+	}
+	return ipcity.Export(stdout, ipcity.ExportFormat(*format), list, options)
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/importer"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"os"
+)
+
+func runImport(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("import", stderr)
+	format := flags.String("format", "", "source format, xdb, geolite2 or ip2location")
+	locations := flags.String("locations", "", "GeoLite2 locations file")
+	columns := flags.String("columns", "", "comma separated names of the positional source fields")
+	fields := flags.String("fields", "", "comma separated column=field mappings of the meta columns")
+	out := flags.String("out", "", "written data file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *out == "" {
+		return fmt.Errorf("a source file and the data file are required")
+	}
+	options := importer.Options{Columns: splitList(*columns)}
+	if *fields != "" {
+		var err error
+		if options.Fields, err = importer.ParseFields(splitList(*fields)...); err != nil {
+			return err
+		}
+	}
+	store, err := importSource(importer.Format(*format), flags.Arg(0), *locations, options)
+	if err != nil {
+		return err
+	}
+	data, err := store.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(*out, data, 0644); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "%s %s %d entities %d meta rows\n",
+		*out, store.Header().ModeName(), store.EntityCount(), store.MetaRowCount())
+	return err
+}
+
+// importSource reads the source file of the format into a store.
+func importSource(format importer.Format, filename, locations string, options importer.Options) (*provider.Store, error) {
+	switch format {
+	case importer.FormatXDB:
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return importer.ReadXDB(data, options)
+	case importer.FormatGeoLite2:
+		blocks, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = blocks.Close() }()
+		if locations == "" {
+			return importer.ReadGeoLite2CSV(blocks, nil, options)
+		}
+		places, err := os.Open(locations)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = places.Close() }()
+		return importer.ReadGeoLite2CSV(blocks, places, options)
+	case importer.FormatIP2Location:
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = file.Close() }()
+		return importer.ReadIP2LocationCSV(file, options)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
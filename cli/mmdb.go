@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/mmdb"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func runToMMDB(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("to-mmdb", stderr)
+	data := flags.String("data", defaultDataFiles, "comma separated data files, the former wins on overlapping networks")
+	out := flags.String("out", "", "mmdb file, stdout if empty")
+	options := mmdb.WriteOptions{}
+	flags.StringVar(&options.DatabaseType, "type", "", "database type")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	client, err := loadClient(*data)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return mmdb.Write(stdout, client.Stores(), options)
+	}
+	buffer := &bytes.Buffer{}
+	if err = mmdb.Write(buffer, client.Stores(), options); err != nil {
+		return err
+	}
+	return os.WriteFile(*out, buffer.Bytes(), 0644)
+}
+
+func runFromMMDB(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("from-mmdb", stderr)
+	in := flags.String("in", "", "mmdb file")
+	out := flags.String("out", "data", "directory of the written ipv4.dat and ipv6.dat")
+	lang := flags.String("lang", "", "map the GeoIP2 record names of the language to the columns, keep all the record paths if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return fmt.Errorf("mmdb file is required")
+	}
+	options := mmdb.ReadOptions{}
+	if *lang != "" {
+		options.Fields = mmdb.GeoIP2Fields(*lang)
+	}
+	ipv4, ipv6, err := mmdb.ReadFile(*in, options)
+	if err != nil {
+		return err
+	}
+	for _, file := range []struct {
+		name  string
+		store *provider.Store
+	}{
+		{"ipv4.dat", ipv4},
+		{"ipv6.dat", ipv6},
+	} {
+		if file.store == nil {
+			continue
+		}
+		encoded, err := file.store.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(*out, file.name)
+		if err = os.WriteFile(filename, encoded, 0644); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(stdout, filename)
+	}
+	return nil
+}
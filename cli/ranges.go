@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"io"
+	"net/netip"
+)
+
+func runRanges(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("ranges", stderr)
+	data := flags.String("data", defaultDataFiles, "comma separated data files")
+	family := flags.String("family", "", "address family, 4 or 6, both if empty")
+	asJSON := flags.Bool("json", false, "print the prefixes as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	list, err := findRanges(*data, *family, flags.Args())
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return json.NewEncoder(stdout).Encode(rangeListResponse(list))
+	}
+	for _, prefix := range list.Prefixes() {
+		if _, err = fmt.Fprintln(stdout, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findRanges loads the data files and finds the ranges of the family matching the filter expressions.
+func findRanges(data, family string, expressions []string) (ipcity.RangeList, error) {
+	filter, err := ipcity.ParseFilter(expressions...)
+	if err != nil {
+		return ipcity.RangeList{}, err
+	}
+	client, err := loadClient(data)
+	if err != nil {
+		return ipcity.RangeList{}, err
+	}
+	return filterFamily(client.FindRanges(filter), family)
+}
+
+// filterFamily keeps the prefixes of the family, 4 or 6, both if empty.
+func filterFamily(list ipcity.RangeList, family string) (ipcity.RangeList, error) {
+	switch family {
+	case "":
+	case "4":
+		list.IPv6 = nil
+	case "6":
+		list.IPv4 = nil
+	default:
+		return ipcity.RangeList{}, fmt.Errorf("invalid family %q, want 4 or 6", family)
+	}
+	return list, nil
+}
+
+// rangeListResponse returns the json form of the range list.
+func rangeListResponse(list ipcity.RangeList) map[string][]string {
+	toStrings := func(prefixes []netip.Prefix) []string {
+		items := make([]string, 0, len(prefixes))
+		for _, prefix := range prefixes {
+			items = append(items, prefix.String())
+		}
+		return items
+	}
+	return map[string][]string{"ipv4": toStrings(list.IPv4), "ipv6": toStrings(list.IPv6)}
+}
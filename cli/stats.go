@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func runStats(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("stats", stderr)
+	data := flags.String("data", defaultDataFiles, "comma separated data files")
+	asJSON := flags.Bool("json", false, "print the stats as json")
+	top := flags.Int("top", 10, "print at most the count of countries, provinces and isps, all if 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	client, err := loadClient(*data)
+	if err != nil {
+		return err
+	}
+	stats := make([]*provider.StoreStats, 0, len(client.Stores()))
+	for _, store := range client.Stores() {
+		s := store.Stats()
+		if *top > 0 {
+			s.Countries, s.Provinces, s.ISPs = topCounts(s.Countries, *top), topCounts(s.Provinces, *top), topCounts(s.ISPs, *top)
+		}
+		stats = append(stats, s)
+	}
+	if *asJSON {
+		return json.NewEncoder(stdout).Encode(stats)
+	}
+	writer := bufio.NewWriter(stdout)
+	for _, s := range stats {
+		printStats(writer, s)
+	}
+	return writer.Flush()
+}
+
+func topCounts(counts []provider.NameCount, n int) []provider.NameCount {
+	if len(counts) > n {
+		return counts[:n]
+	}
+	return counts
+}
+
+func printStats(w io.Writer, stats *provider.StoreStats) {
+	_, _ = fmt.Fprintf(w, "%s covered %d ranges, %s addresses, gaps %d ranges, %s addresses, space %s addresses\n",
+		stats.Mode, stats.Ranges, stats.Covered, stats.GapRanges, stats.Gaps, stats.Space)
+	for _, group := range []struct {
+		name   string
+		counts []provider.NameCount
+	}{
+		{"countries", stats.Countries},
+		{"provinces", stats.Provinces},
+		{"isps", stats.ISPs},
+	} {
+		_, _ = fmt.Fprintf(w, "%s:\n", group.name)
+		for _, c := range group.counts {
+			name := c.Name
+			if name == "" {
+				name = "-"
+			}
+			_, _ = fmt.Fprintf(w, "  %s %d ranges, %s addresses\n", name, c.Ranges, c.Addresses)
+		}
+	}
+	_, _ = fmt.Fprintln(w, "sizes:")
+	for _, size := range stats.Sizes {
+		_, _ = fmt.Fprintf(w, "  /%d %d ranges\n", size.Bits, size.Ranges)
+	}
+	rows := []string{"unreferenced meta rows:", strconv.Itoa(len(stats.Unreferenced))}
+	for _, i := range stats.Unreferenced {
+		rows = append(rows, strconv.Itoa(i))
+	}
+	_, _ = fmt.Fprintln(w, strings.Join(rows, " "))
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"os"
+	"strings"
+)
+
+// Config defines the listeners of the engine.
+type Config struct {
+	// HTTPAddr is the plain HTTP listen address, empty disables it.
+	HTTPAddr string
+	// HTTPSAddr is the HTTPS listen address, empty disables it.
+	HTTPSAddr string
+	// TLSCertFile and TLSKeyFile are the server certificate and key paths.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile is the CA bundle used to verify client certificates,
+	// empty disables client certificate verification.
+	TLSClientCAFile string
+	// TLSClientAuth is "require" (default) or "verify-if-given".
+	TLSClientAuth string
+	// HTTPSocket is the unix socket path serving the HTTP API, empty disables it.
+	HTTPSocket string
+	// LineAddr is the TCP listen address of the line protocol, empty disables it.
+	LineAddr string
+	// LineSocket is the unix socket path of the line protocol, empty disables it.
+	LineSocket string
+	// Policy is the name of the client resolution policy, see ipcity.PolicyName.
+	Policy string
+	// OverlayFiles are the CSV or YAML local override files.
+	OverlayFiles []string
+	// SpecialBlocks enables the classification of special-purpose address blocks.
+	SpecialBlocks bool
+	// ASNFiles are the ASN data files merged into the search response.
+	ASNFiles []string
+	// Strict rejects the malformed data files at load.
+	Strict bool
+	// DivisionsFile is the division table replacing the embedded one.
+	DivisionsFile string
+}
+
+// LoadConfig loads the engine config from environment variables.
+func LoadConfig() *Config {
+	return &Config{
+		HTTPAddr:        lookupEnv("IPCITY_HTTP_ADDR", ":8000"),
+		HTTPSAddr:       lookupEnv("IPCITY_HTTPS_ADDR", ""),
+		TLSCertFile:     lookupEnv("IPCITY_TLS_CERT", ""),
+		TLSKeyFile:      lookupEnv("IPCITY_TLS_KEY", ""),
+		TLSClientCAFile: lookupEnv("IPCITY_TLS_CLIENT_CA", ""),
+		TLSClientAuth:   lookupEnv("IPCITY_TLS_CLIENT_AUTH", "require"),
+		HTTPSocket:      lookupEnv("IPCITY_HTTP_SOCKET", ""),
+		LineAddr:        lookupEnv("IPCITY_LINE_ADDR", ""),
+		LineSocket:      lookupEnv("IPCITY_LINE_SOCKET", ""),
+		Policy:          lookupEnv("IPCITY_POLICY", ipcity.PolicyFirstMatch.String()),
+		OverlayFiles:    splitList(lookupEnv("IPCITY_OVERLAY", "")),
+		SpecialBlocks:   lookupEnv("IPCITY_SPECIAL_BLOCKS", "false") == "true",
+		ASNFiles:        splitList(lookupEnv("IPCITY_ASN_DATA", "")),
+		Strict:          lookupEnv("IPCITY_STRICT", "false") == "true",
+		DivisionsFile:   lookupEnv("IPCITY_DIVISIONS", ""),
+	}
+}
+
+func lookupEnv(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
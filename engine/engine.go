@@ -1,16 +1,25 @@
 package engine
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
 	"github.com/gin-gonic/gin"
 	"io"
 	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 )
 
 func InitEngine() {
 	// init IPCity Data
 	InitIPCity()
+	reloadOnSignal()
 	// disable color
 	gin.DisableConsoleColor()
 	// init log file
@@ -21,41 +30,260 @@ func InitEngine() {
 	engine := gin.Default()
 	// init route
 	engine.GET("search/", searchIPAddress)
+	engine.GET("asn/:number", listASNPrefixes)
+	engine.GET("divisions/:code", searchDivision)
+	engine.GET("ranges/", findRanges)
+	engine.GET("stats/", storeStats)
 	// Start Engine
-	err := engine.Run(":8000")
+	err := serve(engine, LoadConfig())
 	if err != nil {
 		panic(err.Error())
 	}
 }
 
+// serve runs all the configured listeners together and returns when any of them fails.
+func serve(handler http.Handler, config *Config) error {
+	servers := make([]func() error, 0, 5)
+	if config.HTTPAddr != "" {
+		server := &http.Server{Addr: config.HTTPAddr, Handler: handler}
+		servers = append(servers, server.ListenAndServe)
+	}
+	if config.HTTPSAddr != "" {
+		reloader, err := newCertReloader(config)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Addr: config.HTTPSAddr, Handler: handler, TLSConfig: reloader.TLSConfig()}
+		servers = append(servers, func() error { return server.ListenAndServeTLS("", "") })
+	}
+	if config.HTTPSocket != "" {
+		listener, err := listenUnix(config.HTTPSocket)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: handler}
+		servers = append(servers, func() error { return server.Serve(listener) })
+	}
+	if config.LineAddr != "" {
+		listener, err := net.Listen("tcp", config.LineAddr)
+		if err != nil {
+			return err
+		}
+		servers = append(servers, func() error { return serveLine(listener, IPCityClient) })
+	}
+	if config.LineSocket != "" {
+		listener, err := listenUnix(config.LineSocket)
+		if err != nil {
+			return err
+		}
+		servers = append(servers, func() error { return serveLine(listener, IPCityClient) })
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("no listener configured")
+	}
+
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(run func() error) { errs <- run() }(server)
+	}
+	return <-errs
+}
+
+// listenUnix listens on a unix socket, replacing a stale socket file left by a previous run.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	return net.Listen("unix", path)
+}
+
 func searchIPAddress(context *gin.Context) {
 	// load params
 	ip := context.Query("ip")
-	if _ip := net.ParseIP(ip); _ip == nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"ip":          "",
-			"country":     "",
-			"province":    "",
-			"city":        "",
-			"district":    "",
-			"isp":         "",
-			"backboneISP": "",
-			"countryCode": 0,
-			"areaCode":    0,
-		})
+	result, err := IPCityClient.Lookup(ip)
+	if errors.Is(err, ipcity.ErrInvalidAddress) {
+		response := metaResponse(nil)
+		response["ip"] = ""
+		response["translation"] = ""
+		response["scope"] = ""
+		response["countryISO"] = ""
+		context.JSON(http.StatusBadRequest, response)
 		return
 	}
 	// search ip
-	meta := IPCityClient.Search(ip)
-	context.JSON(http.StatusOK, gin.H{
-		"ip":          ip,
-		"country":     meta.Country(),
-		"province":    meta.Province(),
-		"city":        meta.City(),
-		"district":    meta.District(),
-		"isp":         meta.ISP(),
-		"backboneISP": meta.BackboneISP(),
-		"countryCode": meta.CountryCode(),
-		"areaCode":    meta.AreaCode(),
-	})
+	response := metaResponse(result.Meta.Localized(requestLanguages(context)...))
+	response["countryISO"] = result.Meta.ISOCode()
+	response["ip"] = ip
+	response["translation"] = result.Translation.String()
+	response["scope"] = result.Scope.String()
+	if asnEnabled {
+		mergeASN(response, IPCityClient.SearchASN(ip))
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// requestLanguages returns the preferred languages in the comma separated lang param.
+func requestLanguages(context *gin.Context) []string {
+	var langs []string
+	for _, lang := range strings.Split(context.Query("lang"), ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// mergeASN merges the autonomous system into the response.
+func mergeASN(response gin.H, asn *ipcity.ASN) {
+	if asn == nil {
+		asn = &ipcity.ASN{}
+	}
+	response["asn"] = asn.Number
+	response["asOrganization"] = asn.Organization
+	response["asPrefix"] = ""
+	if asn.Prefix.IsValid() {
+		response["asPrefix"] = asn.Prefix.String()
+	}
+}
+
+func listASNPrefixes(context *gin.Context) {
+	// load params
+	number, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(context.Param("number")), "AS"))
+	if err != nil || number <= 0 {
+		context.JSON(http.StatusBadRequest, gin.H{"asn": 0, "organization": "", "prefixes": []string{}})
+		return
+	}
+	// list prefixes
+	asnList := IPCityClient.ASNPrefixes(number)
+	response := gin.H{"asn": number, "organization": "", "prefixes": make([]string, 0, len(asnList))}
+	for _, asn := range asnList {
+		if asn.Organization != "" {
+			response["organization"] = asn.Organization
+		}
+		if asn.Prefix.IsValid() {
+			response["prefixes"] = append(response["prefixes"].([]string), asn.Prefix.String())
+		}
+	}
+	if len(asnList) == 0 {
+		context.JSON(http.StatusNotFound, response)
+		return
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// metaResponse returns all the declared meta fields by name.
+func metaResponse(meta *ipcity.Meta) gin.H {
+	fields := meta.Fields()
+	response := make(gin.H, len(fields)+3)
+	for _, field := range fields {
+		// language variants are selected by the lang param
+		if _, _, ok := provider.SplitLocalizedColumn(field.Name); ok {
+			continue
+		}
+		response[field.Name] = field.Value
+	}
+	return response
+}
+
+func searchDivision(context *gin.Context) {
+	// load params
+	code, err := strconv.Atoi(context.Param("code"))
+	if err != nil || ipcity.DivisionLevelOf(code) == ipcity.DivisionLevelUnknown {
+		context.JSON(http.StatusBadRequest, gin.H{"code": 0})
+		return
+	}
+	// search division
+	division, ok := divisions.Lookup(code)
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"code": code})
+		return
+	}
+	response := divisionResponse(division)
+	chain := make([]gin.H, 0, 3)
+	for _, item := range divisions.Chain(code) {
+		chain = append(chain, divisionResponse(item))
+	}
+	children := make([]gin.H, 0)
+	for _, item := range divisions.Children(code) {
+		children = append(children, divisionResponse(item))
+	}
+	response["chain"] = chain
+	response["children"] = children
+	context.JSON(http.StatusOK, response)
+}
+
+func divisionResponse(division ipcity.Division) gin.H {
+	return gin.H{
+		"code":       division.Code,
+		"name":       division.Name,
+		"level":      division.Level.String(),
+		"parentCode": division.ParentCode,
+	}
+}
+
+func findRanges(context *gin.Context) {
+	// load params
+	filter, err := ipcity.ParseFilter(context.QueryArray("filter")...)
+	family := context.Query("family")
+	if err != nil || (family != "" && family != "4" && family != "6") {
+		context.JSON(http.StatusBadRequest, gin.H{"ipv4": []string{}, "ipv6": []string{}})
+		return
+	}
+	// find ranges
+	list := IPCityClient.FindRanges(filter)
+	response := gin.H{}
+	switch family {
+	case "4":
+		list.IPv6 = nil
+	case "6":
+		list.IPv4 = nil
+	}
+	// export as config format
+	if format := context.Query("format"); format != "" {
+		buffer := &bytes.Buffer{}
+		err = ipcity.Export(buffer, ipcity.ExportFormat(format), list, ipcity.ExportOptions{
+			Name:    context.Query("name"),
+			Target:  context.Query("target"),
+			Value:   context.Query("value"),
+			Default: context.Query("default"),
+		})
+		if err != nil {
+			context.String(http.StatusBadRequest, "%s\n", err)
+			return
+		}
+		context.Data(http.StatusOK, "text/plain; charset=utf-8", buffer.Bytes())
+		return
+	}
+	response["ipv4"] = prefixStrings(list.IPv4)
+	response["ipv6"] = prefixStrings(list.IPv6)
+	context.JSON(http.StatusOK, response)
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	items := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		items = append(items, prefix.String())
+	}
+	return items
+}
+
+func storeStats(context *gin.Context) {
+	// load params
+	family := context.Query("family")
+	if family != "" && family != "4" && family != "6" {
+		context.JSON(http.StatusBadRequest, gin.H{"stores": []*provider.StoreStats{}})
+		return
+	}
+	// count stores of the family
+	stats := make([]*provider.StoreStats, 0, 2)
+	stores, cached := IPCityClient.Stats()
+	for i, store := range stores {
+		switch {
+		case family == "4" && store.Header().AddrBitLen() != 32:
+		case family == "6" && store.Header().AddrBitLen() != 128:
+		default:
+			stats = append(stats, cached[i])
+		}
+	}
+	context.JSON(http.StatusOK, gin.H{"stores": stats})
 }
@@ -1,14 +1,32 @@
 package engine
 
-import "github.com/OVINC-CN/IPCity/ipcity"
+import (
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
 
 var (
 	IPCityClient *ipcity.Client
 	err          error
+	// asnEnabled is true if ASN data is loaded
+	asnEnabled bool
+	// divisions is the administrative division table
+	divisions = ipcity.DefaultDivisions()
 )
 
 func InitIPCity() {
-	IPCityClient = ipcity.NewClient()
+	config := LoadConfig()
+	policy, ok := parsePolicy(config.Policy)
+	if !ok {
+		panic(fmt.Sprintf("unsupported policy %q", config.Policy))
+	}
+	IPCityClient = ipcity.NewClient().WithPolicy(policy).WithSpecialBlocks(config.SpecialBlocks).
+		WithLogger(log.Default()).
+		WithStrict(config.Strict)
 	err = IPCityClient.Load("data/ipv4.dat")
 	if err != nil {
 		panic(err.Error())
@@ -17,4 +35,45 @@ func InitIPCity() {
 	if err != nil {
 		panic(err.Error())
 	}
+	for _, filename := range config.ASNFiles {
+		err = IPCityClient.Load(filename)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+	asnEnabled = len(config.ASNFiles) > 0
+	if config.DivisionsFile != "" {
+		divisions, err = ipcity.LoadDivisions(config.DivisionsFile)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+	for _, filename := range config.OverlayFiles {
+		err = IPCityClient.LoadOverlay(filename)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+}
+
+// reloadOnSignal reloads the data and overlay files when the process receives SIGHUP.
+func reloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := IPCityClient.Reload(); err != nil {
+				log.Printf("reload ipcity data error, %s", err)
+			}
+		}
+	}()
+}
+
+func parsePolicy(name string) (ipcity.Policy, bool) {
+	for policy, policyName := range ipcity.PolicyName {
+		if policyName == name {
+			return policy, true
+		}
+	}
+	return ipcity.PolicyFirstMatch, false
 }
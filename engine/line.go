@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"io"
+	"log"
+	"net"
+	"strings"
+)
+
+// lineMaxLength is the longest request line accepted by the line protocol.
+const lineMaxLength = 256
+
+// serveLine serves the line protocol: one IP per request line, one TSV meta per response line.
+func serveLine(listener net.Listener, client ipcity.ClientInterface) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go handleLine(conn, client)
+	}
+}
+
+func handleLine(conn net.Conn, client ipcity.ClientInterface) {
+	defer func() { _ = conn.Close() }()
+
+	reader := bufio.NewReaderSize(conn, lineMaxLength)
+	writer := bufio.NewWriter(conn)
+	for {
+		request, err := reader.ReadSlice('\n')
+		if err != nil && (len(request) == 0 || err != io.EOF) {
+			if err != io.EOF {
+				log.Printf("line protocol connection %s error, %s", conn.RemoteAddr(), err)
+			}
+			break
+		}
+		ip := strings.TrimSpace(string(request))
+		var meta *ipcity.Meta
+		if net.ParseIP(ip) != nil {
+			meta = client.Search(ip)
+		}
+		if meta == nil {
+			meta = &ipcity.Meta{}
+		}
+		// separators in values are escaped to keep one response per line
+		line, _ := meta.MarshalText()
+		_, _ = writer.Write(line)
+		_ = writer.WriteByte('\n')
+		// flush once the pipelined requests are drained
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	_ = writer.Flush()
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"bufio"
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// testLineMeta is the response of the addresses in 1.0.0.0/8, the tab of the isp is escaped
+	testLineMeta = "中国\t广东\t广州\t\t电信\\t联通\t\t0\t0\n"
+	// testLineEmpty is the response of the blank, invalid and unknown addresses
+	testLineEmpty = "\t\t\t\t\t\t0\t0\n"
+)
+
+func newTestLineClient(t *testing.T) *ipcity.Client {
+	t.Helper()
+	store := provider.NewStore().
+		WithHeader(provider.NewHeader(provider.DataVersionLatest, provider.DataModeIPv4)).
+		WithMetaTable([]*provider.Meta{
+			provider.NewMeta(),
+			provider.NewMeta().WithCountry("中国").WithProvince("广东").WithCity("广州").WithISP("电信\t联通"),
+		}).
+		WithEntityList([]*provider.Entity{
+			provider.NewEntity(0x00000000, 0),
+			provider.NewEntity(0x01000000, 1),
+			provider.NewEntity(0x02000000, 0),
+		})
+	client := ipcity.NewClient()
+	if err := client.LoadStore(store); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// readLines reads the response lines of the connection until it is closed.
+func readLines(t *testing.T, conn net.Conn) []string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var lines []string
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return lines
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestHandleLine(t *testing.T) {
+	client := newTestLineClient(t)
+	server, conn := net.Pipe()
+	go handleLine(server, client)
+	defer func() { _ = conn.Close() }()
+
+	// the pipelined requests are answered in order, one line each
+	go func() { _, _ = io.WriteString(conn, "1.2.3.4\n\n  \ninvalid\n8.8.8.8\n::ffff:1.2.3.4\r\n") }()
+	reader := bufio.NewReader(conn)
+	for i, want := range []string{testLineMeta, testLineEmpty, testLineEmpty, testLineEmpty, testLineEmpty, testLineMeta} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != want {
+			t.Errorf("got response[%d] %q, want %q", i, line, want)
+		}
+	}
+}
+
+func TestHandleLineTooLong(t *testing.T) {
+	client := newTestLineClient(t)
+	server, conn := net.Pipe()
+	go handleLine(server, client)
+
+	// the connection is closed at the request longer than lineMaxLength, the later ones are dropped
+	go func() {
+		_, _ = io.WriteString(conn, "1.2.3.4\n"+strings.Repeat("1", lineMaxLength+1)+"\n1.2.3.4\n")
+	}()
+	if lines := readLines(t, conn); len(lines) != 1 || lines[0] != testLineMeta {
+		t.Errorf("got responses %q, want only %q", lines, testLineMeta)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "line.sock")
+	// leave a stale socket file as a killed process does
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+	if _, err = os.Lstat(path); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("listen on a stale socket error, %s", err)
+	}
+	defer func() { _ = listener.Close() }()
+	client := newTestLineClient(t)
+	go func() { _ = serveLine(listener, client) }()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	// the last request is answered at the end of the input without a newline
+	if _, err = io.WriteString(conn, "1.2.3.4\n1.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.(*net.UnixConn).CloseWrite()
+	if lines := readLines(t, conn); len(lines) != 2 || lines[0] != testLineMeta || lines[1] != testLineMeta {
+		t.Errorf("got responses %q", lines)
+	}
+
+	// a file which is not a socket is kept
+	file := filepath.Join(dir, "data.txt")
+	if err = os.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := listenUnix(file); err == nil {
+		_ = l.Close()
+		t.Error("listen on a regular file got no error")
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "data" {
+		t.Errorf("regular file is changed, %q %v", data, err)
+	}
+}
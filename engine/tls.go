@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader keeps the server certificate and client CA pool in sync with the files on disk.
+type certReloader struct {
+	certFile   string
+	keyFile    string
+	caFile     string
+	clientAuth tls.ClientAuthType
+
+	mutex     sync.RWMutex
+	config    *tls.Config
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+// reloadCheckInterval limits how often the files are checked during handshakes.
+const reloadCheckInterval = time.Second
+
+// tlsNextProtos are the ALPN protocols of the https listener, the config returned by
+// GetConfigForClient replaces the server config and must announce them as well.
+var tlsNextProtos = []string{"h2", "http/1.1"}
+
+func newCertReloader(config *Config) (*certReloader, error) {
+	if config.TLSCertFile == "" || config.TLSKeyFile == "" {
+		return nil, fmt.Errorf("https requires both IPCITY_TLS_CERT and IPCITY_TLS_KEY")
+	}
+	r := &certReloader{
+		certFile:   config.TLSCertFile,
+		keyFile:    config.TLSKeyFile,
+		caFile:     config.TLSClientCAFile,
+		clientAuth: tls.RequireAndVerifyClientCert,
+	}
+	switch config.TLSClientAuth {
+	case "", "require":
+	case "verify-if-given":
+		r.clientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("unsupported client auth %q", config.TLSClientAuth)
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+func (r *certReloader) statFiles() ([]time.Time, error) {
+	files := r.files()
+	modTimes := make([]time.Time, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (r *certReloader) reload() error {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate error, %s", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   tlsNextProtos,
+	}
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("load client ca error, %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in client ca %s", r.caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = r.clientAuth
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.config = config
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if time.Since(r.checkedAt) < reloadCheckInterval {
+		return false
+	}
+	r.checkedAt = time.Now()
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return false
+	}
+	for i := range modTimes {
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// getConfigForClient returns the current config, reloading it first if the files changed.
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	if r.changed() {
+		if err := r.reload(); err != nil {
+			// keep serving the previous certificate until the files are consistent again
+			log.Printf("reload tls certificate error, %s", err)
+		}
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.config, nil
+}
+
+// getCertificate returns the current certificate, net/http requires it to serve without certificate files.
+func (r *certReloader) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	config, err := r.getConfigForClient(hello)
+	if err != nil {
+		return nil, err
+	}
+	return &config.Certificates[0], nil
+}
+
+// TLSConfig returns a tls config that always serves the latest certificate.
+func (r *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         tlsNextProtos,
+		GetCertificate:     r.getCertificate,
+		GetConfigForClient: r.getConfigForClient,
+	}
+}
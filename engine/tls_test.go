@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate with its key, signed by the parent or self-signed if the parent is nil.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and key files, their modification time is set to modTime.
+func (c *testCert) write(t *testing.T, certFile, keyFile string, modTime time.Time) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: c.der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err = os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tlsCertificate returns the certificate and key as a client certificate.
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// serveTestTLS serves the reloader config the way the https listener does and returns the server url.
+func serveTestTLS(t *testing.T, reloader *certReloader) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }),
+		TLSConfig: reloader.TLSConfig(),
+		ErrorLog:  log.New(io.Discard, "", 0),
+	}
+	go func() { _ = server.ServeTLS(listener, "", "") }()
+	t.Cleanup(func() { _ = server.Close() })
+	return "https://" + listener.Addr().String()
+}
+
+func newTestClient(ca *testCert, certs ...tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certs},
+		DisableKeepAlives: true,
+		ForceAttemptHTTP2: true,
+	}}
+}
+
+func TestCertReloaderHotReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	ca := newTestCert(t, "ca", nil)
+	newTestCert(t, "first", ca).write(t, certFile, keyFile, time.Now().Add(-time.Minute))
+	reloader, err := newCertReloader(&Config{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// net/http of go1.20 refuses to serve a config without a certificate or GetCertificate
+	if config := reloader.TLSConfig(); config.GetCertificate == nil && len(config.Certificates) == 0 {
+		t.Fatal("tls config has no certificate")
+	}
+	url := serveTestTLS(t, reloader)
+	client := newTestClient(ca)
+
+	peerName := func() string {
+		t.Helper()
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		// the reloaded config keeps the ALPN protocols of the server
+		if resp.ProtoMajor != 2 {
+			t.Errorf("got protocol %s, want HTTP/2", resp.Proto)
+		}
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	if name := peerName(); name != "first" {
+		t.Fatalf("got certificate %q, want first", name)
+	}
+
+	newTestCert(t, "second", ca).write(t, certFile, keyFile, time.Now())
+	// skip the check interval instead of sleeping
+	reloader.mutex.Lock()
+	reloader.checkedAt = time.Time{}
+	reloader.mutex.Unlock()
+	if name := peerName(); name != "second" {
+		t.Errorf("got certificate %q after reload, want second", name)
+	}
+}
+
+func TestCertReloaderRequireClientCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	caFile := filepath.Join(dir, "ca.crt")
+	ca := newTestCert(t, "ca", nil)
+	newTestCert(t, "server", ca).write(t, certFile, keyFile, time.Now())
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := newCertReloader(&Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloader.clientAuth != tls.RequireAndVerifyClientCert {
+		t.Fatalf("got client auth %s, want RequireAndVerifyClientCert", reloader.clientAuth)
+	}
+	url := serveTestTLS(t, reloader)
+
+	if resp, err := newTestClient(ca).Get(url); err == nil {
+		_ = resp.Body.Close()
+		t.Error("request without a client certificate got no error")
+	}
+	other := newTestCert(t, "other", nil)
+	if resp, err := newTestClient(ca, newTestCert(t, "client", other).tlsCertificate()).Get(url); err == nil {
+		_ = resp.Body.Close()
+		t.Error("request with an unknown client certificate got no error")
+	}
+	resp, err := newTestClient(ca, newTestCert(t, "client", ca).tlsCertificate()).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,120 @@
+package ipcity
+
+import (
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"net/netip"
+	"sort"
+)
+
+// ASN 自治系统信息
+type ASN struct {
+	// Number 自治系统号
+	Number int
+	// Organization 自治系统所属组织
+	Organization string
+	// Prefix 路由前缀
+	Prefix netip.Prefix
+}
+
+// newASNs 从ASN数据的一行生成自治系统信息, 没有路由前缀时按地址段的每个前缀各生成一条
+func newASNs(meta *Meta, r Range) []ASN {
+	if prefix, err := netip.ParsePrefix(meta.ASPrefix()); err == nil {
+		return []ASN{{Number: meta.ASN(), Organization: meta.ASOrganization(), Prefix: prefix}}
+	}
+	prefixes := r.Prefixes()
+	if len(prefixes) == 0 {
+		return []ASN{{Number: meta.ASN(), Organization: meta.ASOrganization()}}
+	}
+	list := make([]ASN, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		list = append(list, ASN{Number: meta.ASN(), Organization: meta.ASOrganization(), Prefix: prefix})
+	}
+	return list
+}
+
+// newASN 从ASN数据的一行生成ip所属的自治系统信息, 没有路由前缀时使用地址段中包含ip的前缀
+func newASN(meta *Meta, r Range, addr netip.Addr) ASN {
+	list := newASNs(meta, r)
+	for _, asn := range list {
+		if asn.Prefix.Contains(addr) {
+			return asn
+		}
+	}
+	return list[0]
+}
+
+// SearchASN 查询ip所属的自治系统, 未命中返回nil
+func (c *Client) SearchASN(addr string) *ASN {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return nil
+	}
+	return c.SearchASNAddr(ip)
+}
+
+// SearchASNAddr 查询已解析的ip所属的自治系统, 内嵌IPv4的IPv6地址优先按IPv4查询
+func (c *Client) SearchASNAddr(addr netip.Addr) *ASN {
+	set := c.stores.Load()
+	if set == nil {
+		return nil
+	}
+	if ipv4, translation := EmbeddedIPv4(addr); translation != TranslationNone {
+		if asn := searchASN(set.byMode[provider.DataModeASN], ipv4); asn != nil {
+			return asn
+		}
+	}
+	return searchASN(set.byMode[provider.DataModeASN], addr)
+}
+
+func searchASN(stores []*Store, addr netip.Addr) *ASN {
+	for _, store := range stores {
+		if store.Header().AddrBitLen() != addr.BitLen() {
+			continue
+		}
+		if meta, r := store.SearchRange(addr); meta.ASN() != 0 {
+			asn := newASN(meta, r, addr)
+			return &asn
+		}
+	}
+	return nil
+}
+
+// ASNPrefixes 返回自治系统的所有路由前缀
+func (c *Client) ASNPrefixes(number int) []ASN {
+	set := c.stores.Load()
+	if set == nil {
+		return nil
+	}
+	set.asnOnce.Do(set.indexASN)
+	return set.asnIndex[number]
+}
+
+// indexASN 按自治系统号索引ASN数据的所有路由前缀
+func (set *storeSet) indexASN() {
+	set.asnIndex = make(map[int][]ASN)
+	for _, store := range set.byMode[provider.DataModeASN] {
+		for i := 0; i < store.EntityCount(); i++ {
+			meta := store.Meta(int(store.Entity(i).MetaRowIndex()))
+			if meta.ASN() == 0 {
+				continue
+			}
+			set.asnIndex[meta.ASN()] = append(set.asnIndex[meta.ASN()], newASNs(meta, store.EntityRange(i))...)
+		}
+	}
+	for number, list := range set.asnIndex {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Prefix.Addr() != list[j].Prefix.Addr() {
+				return list[i].Prefix.Addr().Less(list[j].Prefix.Addr())
+			}
+			return list[i].Prefix.Bits() < list[j].Prefix.Bits()
+		})
+		// ranges sharing a meta row report the same prefix
+		unique := list[:0]
+		for i, asn := range list {
+			if i == 0 || asn.Prefix != list[i-1].Prefix {
+				unique = append(unique, asn)
+			}
+		}
+		set.asnIndex[number] = unique
+	}
+}
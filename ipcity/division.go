@@ -0,0 +1,335 @@
+package ipcity
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DivisionLevel is the level of an administrative division.
+type DivisionLevel byte
+
+const (
+	// DivisionLevelUnknown means the code is not a division code.
+	DivisionLevelUnknown = DivisionLevel(0)
+	// DivisionLevelProvince means the division is a province, an autonomous region,
+	// a municipality or a special administrative region, the code ends with 0000.
+	DivisionLevelProvince = DivisionLevel(1)
+	// DivisionLevelCity means the division is a prefecture-level division, the code ends with 00.
+	DivisionLevelCity = DivisionLevel(2)
+	// DivisionLevelDistrict means the division is a county-level division.
+	DivisionLevelDistrict = DivisionLevel(3)
+)
+
+// DivisionLevelName is a mapping for division level name.
+var DivisionLevelName = map[DivisionLevel]string{
+	DivisionLevelUnknown:  "unknown",
+	DivisionLevelProvince: "province",
+	DivisionLevelCity:     "city",
+	DivisionLevelDistrict: "district",
+}
+
+func (l DivisionLevel) String() string {
+	if name, ok := DivisionLevelName[l]; ok {
+		return name
+	}
+	return DivisionLevelName[DivisionLevelUnknown]
+}
+
+// DivisionLevelOf returns the level of the 6 digits division code.
+func DivisionLevelOf(code int) DivisionLevel {
+	switch {
+	case code < 110000 || code > 999999:
+		return DivisionLevelUnknown
+	case code%10000 == 0:
+		return DivisionLevelProvince
+	case code%100 == 0:
+		return DivisionLevelCity
+	default:
+		return DivisionLevelDistrict
+	}
+}
+
+// divisionParentCode returns the code of the parent division, 0 for a province.
+func divisionParentCode(code int) int {
+	switch DivisionLevelOf(code) {
+	case DivisionLevelCity:
+		return code / 10000 * 10000
+	case DivisionLevelDistrict:
+		return code / 100 * 100
+	default:
+		return 0
+	}
+}
+
+// divisionPlaceholders are the names of the city level rows which group the districts
+// directly under a municipality or a province and are not cities themselves.
+var divisionPlaceholders = map[string]bool{
+	"市辖区":         true,
+	"县":           true,
+	"省直辖县级行政区划":   true,
+	"自治区直辖县级行政区划": true,
+}
+
+// divisionSuffixes are stripped from the names when they are compared, longest first.
+var divisionSuffixes = []string{
+	"维吾尔自治区", "壮族自治区", "回族自治区", "特别行政区", "自治区", "自治州", "自治县",
+	"地区", "新区", "省", "市", "盟", "区", "县",
+}
+
+// DivisionShortName returns the name without the division type suffix, e.g. "广东" for "广东省".
+func DivisionShortName(name string) string {
+	name = strings.TrimSpace(name)
+	for _, suffix := range divisionSuffixes {
+		if short := strings.TrimSuffix(name, suffix); short != name && short != "" {
+			return short
+		}
+	}
+	return name
+}
+
+// Division defines an administrative division.
+type Division struct {
+	Code       int
+	Name       string
+	Level      DivisionLevel
+	ParentCode int
+}
+
+// Divisions is a table of administrative divisions keyed by the division code.
+type Divisions struct {
+	byCode map[int]*Division
+}
+
+// NewDivisions returns a new empty division table.
+func NewDivisions() *Divisions {
+	return &Divisions{byCode: make(map[int]*Division)}
+}
+
+// Add the division to the table, the level and the parent are derived from the code.
+func (d *Divisions) Add(code int, name string) error {
+	if DivisionLevelOf(code) == DivisionLevelUnknown {
+		return fmt.Errorf("invalid division code %d", code)
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		return fmt.Errorf("empty name of division %d", code)
+	}
+	d.byCode[code] = &Division{
+		Code: code, Name: name, Level: DivisionLevelOf(code), ParentCode: divisionParentCode(code),
+	}
+	return nil
+}
+
+// Len returns the count of the divisions.
+func (d *Divisions) Len() int {
+	if d != nil {
+		return len(d.byCode)
+	}
+	return 0
+}
+
+// Lookup returns the division of the code.
+func (d *Divisions) Lookup(code int) (Division, bool) {
+	if d != nil {
+		if division, ok := d.byCode[code]; ok {
+			return *division, true
+		}
+	}
+	return Division{}, false
+}
+
+// Chain returns the division of the code and its ancestors from the province down,
+// the placeholder city rows such as "市辖区" are skipped.
+func (d *Divisions) Chain(code int) []Division {
+	var chain []Division
+	for code != 0 {
+		if division, ok := d.Lookup(code); ok && !divisionPlaceholders[division.Name] {
+			chain = append(chain, division)
+		}
+		code = divisionParentCode(code)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// Children returns the divisions directly under the code ordered by code,
+// the districts under a placeholder city row are included for a province.
+func (d *Divisions) Children(code int) []Division {
+	var children []Division
+	if d == nil {
+		return children
+	}
+	for _, division := range d.byCode {
+		parent := division.ParentCode
+		if placeholder, ok := d.byCode[parent]; ok && divisionPlaceholders[placeholder.Name] {
+			parent = placeholder.ParentCode
+		}
+		if parent == code && !divisionPlaceholders[division.Name] {
+			children = append(children, *division)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Code < children[j].Code })
+	return children
+}
+
+// divisionNames returns the province, city and district names of the code, the city of
+// a division directly under a municipality is the municipality itself.
+func (d *Divisions) divisionNames(code int) (province, city, district string, ok bool) {
+	chain := d.Chain(code)
+	if len(chain) == 0 || chain[len(chain)-1].Code != code {
+		return "", "", "", false
+	}
+	for _, division := range chain {
+		switch division.Level {
+		case DivisionLevelProvince:
+			province = division.Name
+		case DivisionLevelCity:
+			city = division.Name
+		case DivisionLevelDistrict:
+			district = division.Name
+		}
+	}
+	if city == "" && district != "" {
+		if parent, ok := d.Lookup(divisionParentCode(code)); ok && divisionPlaceholders[parent.Name] {
+			city = province
+		}
+	}
+	return province, city, district, true
+}
+
+// DivisionError describes a meta field which is not consistent with the area code.
+type DivisionError struct {
+	AreaCode int
+	// Column is the name of the meta field, empty if the area code is unknown
+	Column string
+	Value  string
+	Want   string
+}
+
+func (e *DivisionError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("unknown area code %d", e.AreaCode)
+	}
+	return fmt.Sprintf("%s %q is not consistent with area code %d, want %q", e.Column, e.Value, e.AreaCode, e.Want)
+}
+
+// Validate checks the province, city and district of the meta against its area code,
+// the names are compared without the division type suffix and the empty names are skipped.
+// A meta without area code is valid.
+func (d *Divisions) Validate(meta *Meta) error {
+	if meta.AreaCode() == 0 {
+		return nil
+	}
+	province, city, district, ok := d.divisionNames(meta.AreaCode())
+	if !ok {
+		return &DivisionError{AreaCode: meta.AreaCode()}
+	}
+	for _, field := range []struct {
+		column, value, want string
+	}{
+		{FieldProvince, meta.Province(), province},
+		{FieldCity, meta.City(), city},
+		{FieldDistrict, meta.District(), district},
+	} {
+		if field.value != "" && field.want != "" && DivisionShortName(field.value) != DivisionShortName(field.want) {
+			return &DivisionError{AreaCode: meta.AreaCode(), Column: field.column, Value: field.value, Want: field.want}
+		}
+	}
+	return nil
+}
+
+// Normalize returns a copy of the meta with the province, city and district names
+// of the area code in the table, the levels below the area code are kept.
+// The meta is returned as is if the area code is unknown.
+func (d *Divisions) Normalize(meta *Meta) *Meta {
+	province, city, district, ok := d.divisionNames(meta.AreaCode())
+	if meta == nil || !ok {
+		return meta
+	}
+	normalized := *meta
+	for column, name := range map[string]string{FieldProvince: province, FieldCity: city, FieldDistrict: district} {
+		if name != "" {
+			normalized.WithField(column, name)
+		}
+	}
+	return &normalized
+}
+
+// ReadDivisions reads the division table from the csv of code and name rows,
+// lines starting with # and a header row starting with "code" are skipped.
+func ReadDivisions(reader io.Reader) (*Divisions, error) {
+	divisions := NewDivisions()
+	records := csv.NewReader(reader)
+	records.Comment = '#'
+	records.FieldsPerRecord = -1
+	for row := 0; ; row++ {
+		record, err := records.Read()
+		if err == io.EOF {
+			return divisions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read divisions error, %s", err)
+		}
+		if row == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "code") {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("read divisions row[%d] error, want code and name", row)
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("read divisions row[%d] code error, %s", row, err)
+		}
+		if err = divisions.Add(code, record[1]); err != nil {
+			return nil, fmt.Errorf("read divisions row[%d] error, %s", row, err)
+		}
+	}
+}
+
+// LoadDivisions loads the division table from a csv file, see ReadDivisions.
+func LoadDivisions(filename string) (*Divisions, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return ReadDivisions(file)
+}
+
+//go:embed division.csv
+var divisionData []byte
+
+var defaultDivisions = mustReadDivisions(divisionData)
+
+func mustReadDivisions(data []byte) *Divisions {
+	divisions, err := ReadDivisions(bytes.NewReader(data))
+	if err != nil {
+		panic(fmt.Sprintf("parse divisions error, %s", err))
+	}
+	return divisions
+}
+
+// DefaultDivisions returns the embedded division table of all the county-level divisions,
+// the table is shared and must not be modified.
+func DefaultDivisions() *Divisions {
+	return defaultDivisions
+}
+
+// ValidateStore validates the meta rows of the store, the errors are keyed by the meta row index.
+func (d *Divisions) ValidateStore(store *Store) map[int]error {
+	errs := make(map[int]error)
+	for i, meta := range store.MetaTable() {
+		if err := d.Validate(meta); err != nil {
+			errs[i] = err
+		}
+	}
+	return errs
+}
@@ -0,0 +1,208 @@
+package ipcity
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"net/netip"
+	"regexp"
+	"sort"
+)
+
+// ExportFormat is the name of a config format the ranges are exported as.
+type ExportFormat string
+
+const (
+	// ExportCIDR is a plain list of prefixes, one per line.
+	ExportCIDR = ExportFormat("cidr")
+	// ExportIPSet is an ipset restore file with a hash:net set per address family.
+	ExportIPSet = ExportFormat("ipset")
+	// ExportNFTables is an nftables table with an interval set per address family.
+	ExportNFTables = ExportFormat("nftables")
+	// ExportIPTables is a list of iptables and ip6tables commands appending a rule per prefix.
+	ExportIPTables = ExportFormat("iptables")
+	// ExportNginx is an nginx geo block.
+	ExportNginx = ExportFormat("nginx")
+	// ExportHAProxy is an HAProxy map file for map_ip.
+	ExportHAProxy = ExportFormat("haproxy")
+)
+
+// ExportOptions are the options of the exported configs.
+type ExportOptions struct {
+	// Name is the set, table, chain or geo variable name, "ipcity" if empty,
+	// the IPv6 ipset is suffixed with "6", the nftables sets with "_v4" and "_v6"
+	Name string
+	// Target is the iptables rule target, "ACCEPT" if empty
+	Target string
+	// Value is the nginx geo and HAProxy map value of the prefixes, "1" if empty
+	Value string
+	// Default is the nginx geo default value, "0" if empty
+	Default string
+}
+
+var (
+	// exportNamePattern limits the names to the identifiers every format accepts.
+	exportNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,27}$`)
+	// exportValuePattern keeps the values from breaking the config syntax.
+	exportValuePattern = regexp.MustCompile(`^[^\s;{}"'#\\]+$`)
+)
+
+func (o ExportOptions) withDefaults() (ExportOptions, error) {
+	if o.Name == "" {
+		o.Name = "ipcity"
+	}
+	if !exportNamePattern.MatchString(o.Name) {
+		return o, fmt.Errorf("invalid export name %q", o.Name)
+	}
+	if o.Target == "" {
+		o.Target = "ACCEPT"
+	}
+	if !exportNamePattern.MatchString(o.Target) {
+		return o, fmt.Errorf("invalid export target %q", o.Target)
+	}
+	if o.Value == "" {
+		o.Value = "1"
+	}
+	if o.Default == "" {
+		o.Default = "0"
+	}
+	if !exportValuePattern.MatchString(o.Value) || !exportValuePattern.MatchString(o.Default) {
+		return o, fmt.Errorf("invalid export value %q or default %q", o.Value, o.Default)
+	}
+	return o, nil
+}
+
+// exporters write the range list in the format.
+var exporters = map[ExportFormat]func(w *bufio.Writer, list RangeList, o ExportOptions){
+	ExportCIDR:     exportCIDR,
+	ExportIPSet:    exportIPSet,
+	ExportNFTables: exportNFTables,
+	ExportIPTables: exportIPTables,
+	ExportNginx:    exportNginx,
+	ExportHAProxy:  exportHAProxy,
+}
+
+// ExportFormats returns the names of the supported formats.
+func ExportFormats() []ExportFormat {
+	formats := make([]ExportFormat, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i] < formats[j] })
+	return formats
+}
+
+// Export writes the prefixes of the range list in the format, the adjacent prefixes are merged.
+func Export(w io.Writer, format ExportFormat, list RangeList, options ExportOptions) error {
+	exporter, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+	options, err := options.withDefaults()
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(w)
+	exporter(writer, NewRangeList(list.Prefixes()...), options)
+	return writer.Flush()
+}
+
+// NewRangeList returns the range list of the fewest prefixes covering the prefixes.
+func NewRangeList(prefixes ...netip.Prefix) RangeList {
+	ranges := make([]provider.Range, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		ranges = append(ranges, provider.PrefixRange(prefix))
+	}
+	return newRangeList(ranges)
+}
+
+func exportCIDR(w *bufio.Writer, list RangeList, _ ExportOptions) {
+	for _, prefix := range list.Prefixes() {
+		_, _ = fmt.Fprintln(w, prefix)
+	}
+}
+
+func exportIPSet(w *bufio.Writer, list RangeList, o ExportOptions) {
+	for _, set := range []struct {
+		name, family string
+		prefixes     []netip.Prefix
+	}{
+		{o.Name, "inet", list.IPv4},
+		{o.Name + "6", "inet6", list.IPv6},
+	} {
+		if len(set.prefixes) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "create %s hash:net family %s maxelem %d -exist\n",
+			set.name, set.family, maxInt(len(set.prefixes), 65536))
+		for _, prefix := range set.prefixes {
+			_, _ = fmt.Fprintf(w, "add %s %s -exist\n", set.name, prefix)
+		}
+	}
+}
+
+func exportNFTables(w *bufio.Writer, list RangeList, o ExportOptions) {
+	_, _ = fmt.Fprintf(w, "table inet %s {\n", o.Name)
+	for _, set := range []struct {
+		name, addrType string
+		prefixes       []netip.Prefix
+	}{
+		{o.Name + "_v4", "ipv4_addr", list.IPv4},
+		{o.Name + "_v6", "ipv6_addr", list.IPv6},
+	} {
+		_, _ = fmt.Fprintf(w, "\tset %s {\n\t\ttype %s\n\t\tflags interval\n", set.name, set.addrType)
+		if len(set.prefixes) > 0 {
+			_, _ = fmt.Fprint(w, "\t\telements = {\n")
+			for i, prefix := range set.prefixes {
+				separator := ","
+				if i == len(set.prefixes)-1 {
+					separator = ""
+				}
+				_, _ = fmt.Fprintf(w, "\t\t\t%s%s\n", prefix, separator)
+			}
+			_, _ = fmt.Fprint(w, "\t\t}\n")
+		}
+		_, _ = fmt.Fprint(w, "\t}\n")
+	}
+	_, _ = fmt.Fprint(w, "}\n")
+}
+
+func exportIPTables(w *bufio.Writer, list RangeList, o ExportOptions) {
+	for _, rules := range []struct {
+		command  string
+		prefixes []netip.Prefix
+	}{
+		{"iptables", list.IPv4},
+		{"ip6tables", list.IPv6},
+	} {
+		if len(rules.prefixes) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s -N %s 2>/dev/null || %s -F %s\n", rules.command, o.Name, rules.command, o.Name)
+		for _, prefix := range rules.prefixes {
+			_, _ = fmt.Fprintf(w, "%s -A %s -s %s -j %s\n", rules.command, o.Name, prefix, o.Target)
+		}
+	}
+}
+
+func exportNginx(w *bufio.Writer, list RangeList, o ExportOptions) {
+	_, _ = fmt.Fprintf(w, "geo $%s {\n\tdefault %s;\n", o.Name, o.Default)
+	for _, prefix := range list.Prefixes() {
+		_, _ = fmt.Fprintf(w, "\t%s %s;\n", prefix, o.Value)
+	}
+	_, _ = fmt.Fprint(w, "}\n")
+}
+
+func exportHAProxy(w *bufio.Writer, list RangeList, o ExportOptions) {
+	for _, prefix := range list.Prefixes() {
+		_, _ = fmt.Fprintf(w, "%s %s\n", prefix, o.Value)
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
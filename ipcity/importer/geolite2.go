@@ -0,0 +1,91 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"net/netip"
+)
+
+// GeoLite2Fields are the default fields of the GeoLite2 City, Country and ASN records.
+var GeoLite2Fields = map[string]string{
+	provider.ColumnCountry:  "country_name",
+	provider.ColumnProvince: "subdivision_1_name",
+	provider.ColumnCity:     "city_name",
+	provider.ColumnISP:      "autonomous_system_organization",
+}
+
+// ReadGeoLite2CSV reads the GeoLite2 blocks and locations CSV files into a store of the family of
+// the block networks. A block joins the location of its geoname_id, or of its registered_country_geoname_id
+// if empty. The locations may be nil for the ASN blocks. The options columns are unused.
+func ReadGeoLite2CSV(blocks, locations io.Reader, options Options) (*provider.Store, error) {
+	options, err := options.withDefaults(nil, GeoLite2Fields)
+	if err != nil {
+		return nil, err
+	}
+	places := make(map[string]map[string]string)
+	if locations != nil {
+		err = readCSV(locations, func(r map[string]string) error {
+			places[r["geoname_id"]] = r
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("read locations error, %s", err)
+		}
+	}
+
+	var builder *provider.StoreBuilder
+	err = readCSV(blocks, func(r map[string]string) error {
+		prefix, err := netip.ParsePrefix(r["network"])
+		if err != nil {
+			return err
+		}
+		if builder == nil {
+			mode := provider.DataModeIPv4
+			if prefix.Addr().Is6() {
+				mode = provider.DataModeIPv6
+			}
+			builder = provider.NewStoreBuilder(mode)
+		}
+		id := r["geoname_id"]
+		if id == "" {
+			id = r["registered_country_geoname_id"]
+		}
+		for name, value := range places[id] {
+			if _, ok := r[name]; !ok {
+				r[name] = value
+			}
+		}
+		return builder.Add(provider.PrefixRange(prefix), newMeta(options.Fields, r))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read blocks error, %s", err)
+	}
+	if builder == nil {
+		return nil, fmt.Errorf("no block")
+	}
+	return builder.Build(), nil
+}
+
+// readCSV calls fn with the rows of the CSV named by its header row.
+func readCSV(reader io.Reader, fn func(map[string]string) error) error {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	for line := 2; ; line++ {
+		values, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(record(header, values, "")); err != nil {
+			return fmt.Errorf("line %d, %s", line, err)
+		}
+	}
+}
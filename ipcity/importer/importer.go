@@ -0,0 +1,108 @@
+// Package importer converts the data files of other IP geolocation vendors into stores.
+//
+// An importer reads every range of a source file as a record of named source fields and
+// maps the fields to the meta columns. The records are positional for ip2region xdb and
+// IP2Location CSV, their field names come from Options.Columns. GeoLite2 CSV records are
+// named by the header rows of the blocks and locations files.
+//
+// The built stores are ordinary provider stores of the latest data version, they can be
+// marshaled as ipCT files or loaded into a client by ipcity.Client.LoadStore.
+package importer
+
+import (
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"strconv"
+	"strings"
+)
+
+// Format is the name of a source format.
+type Format string
+
+const (
+	// FormatXDB is the ip2region xdb binary format of version 2.
+	FormatXDB = Format("xdb")
+	// FormatGeoLite2 is the GeoLite2 and GeoIP2 CSV format of a blocks and a locations file.
+	FormatGeoLite2 = Format("geolite2")
+	// FormatIP2Location is the IP2Location CSV format of decimal address ranges.
+	FormatIP2Location = Format("ip2location")
+)
+
+// Options are the options of an importer.
+type Options struct {
+	// Columns names the positional source fields, the default columns of the format if nil
+	Columns []string
+	// Fields maps the meta columns to the source fields, the default fields of the format if nil,
+	// the country code is a telephone dialing code, the sources have none by default
+	Fields map[string]string
+}
+
+// withDefaults returns the options with the default columns and fields of the format,
+// the fields must name the columns if the format has positional columns.
+func (o Options) withDefaults(columns []string, fields map[string]string) (Options, error) {
+	if o.Columns == nil {
+		o.Columns = columns
+	}
+	if o.Fields == nil {
+		o.Fields = fields
+	}
+	known := make(map[string]bool, len(o.Columns))
+	for _, column := range o.Columns {
+		known[column] = true
+	}
+	for column, field := range o.Fields {
+		if provider.DefaultSchema.Index(column) < 0 {
+			return o, fmt.Errorf("unknown meta column %q", column)
+		}
+		// the fields of the formats without positional columns are checked by the records
+		if o.Columns != nil && !known[field] {
+			return o, fmt.Errorf("unknown source field %q of meta column %q", field, column)
+		}
+	}
+	return o, nil
+}
+
+// ParseFields parses the "column=field" expressions into the fields of the options.
+func ParseFields(expressions ...string) (map[string]string, error) {
+	fields := make(map[string]string, len(expressions))
+	for _, expression := range expressions {
+		column, field, ok := strings.Cut(expression, "=")
+		if !ok || column == "" || field == "" {
+			return nil, fmt.Errorf("invalid field %q, want column=field", expression)
+		}
+		fields[column] = field
+	}
+	return fields, nil
+}
+
+// record returns the source fields named by the columns, the placeholder values are cleared.
+func record(columns, values []string, placeholder string) map[string]string {
+	r := make(map[string]string, len(columns))
+	for i, column := range columns {
+		if i < len(values) {
+			if v := strings.TrimSpace(values[i]); v != placeholder {
+				r[column] = v
+			}
+		}
+	}
+	return r
+}
+
+// newMeta returns the meta of the mapped source fields, the malformed numbers are dropped.
+func newMeta(fields map[string]string, r map[string]string) *provider.Meta {
+	meta := provider.NewMeta()
+	for column, field := range fields {
+		value := r[field]
+		if value == "" {
+			continue
+		}
+		if provider.DefaultSchema.Column(provider.DefaultSchema.Index(column)).Type != provider.ColumnTypeInt {
+			meta.WithField(column, value)
+			continue
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			meta.WithField(column, n)
+		}
+	}
+	return meta
+}
@@ -0,0 +1,175 @@
+package importer
+
+import (
+	"encoding/binary"
+	"github.com/OVINC-CN/IPCity/ipcity"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// encodeTestXDB returns an xdb of the segments of the first ip, the last ip and the region.
+func encodeTestXDB(segments []struct {
+	first, last uint32
+	region      string
+}) []byte {
+	data := make([]byte, xdbHeaderLength+256*256*8)
+	binary.LittleEndian.PutUint16(data, 2)
+	binary.LittleEndian.PutUint32(data[4:], 1700000000)
+	ptrs := make([]uint32, len(segments))
+	for i, s := range segments {
+		ptrs[i] = uint32(len(data))
+		data = append(data, s.region...)
+	}
+	start := uint32(len(data))
+	for i, s := range segments {
+		var b [xdbSegmentIndexSize]byte
+		binary.LittleEndian.PutUint32(b[:], s.first)
+		binary.LittleEndian.PutUint32(b[4:], s.last)
+		binary.LittleEndian.PutUint16(b[8:], uint16(len(s.region)))
+		binary.LittleEndian.PutUint32(b[10:], ptrs[i])
+		data = append(data, b[:]...)
+	}
+	binary.LittleEndian.PutUint32(data[8:], start)
+	binary.LittleEndian.PutUint32(data[12:], uint32(len(data)-xdbSegmentIndexSize))
+	return data
+}
+
+func TestReadXDB(t *testing.T) {
+	data := encodeTestXDB([]struct {
+		first, last uint32
+		region      string
+	}{
+		{0x00000000, 0x00FFFFFF, "0|0|0|内网IP|内网IP"},
+		{0x01000000, 0x01FFFFFF, "中国|0|广东省|广州市|电信"},
+		{0x02000000, 0xFFFFFFFF, "美国|0|0|0|0"},
+	})
+	store, err := ReadXDB(data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := ipcity.NewClient()
+	if err = client.LoadStore(store); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]string{
+		"0.0.0.1": "内网IP内网IP",
+		"1.2.3.4": "中国广东省广州市电信",
+		"8.8.8.8": "美国",
+	} {
+		meta := client.Search(addr)
+		if got := meta.Country() + meta.Province() + meta.City() + meta.ISP(); got != want {
+			t.Errorf("search %s got %q, want %q", addr, got, want)
+		}
+	}
+	if store.Header().SourceUpdatedTime().Unix() != 1700000000 {
+		t.Errorf("unexpected source updated time %s", store.Header().SourceUpdatedTime())
+	}
+
+	store, err = ReadXDB(data, Options{Fields: map[string]string{provider.ColumnCity: "province"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := store.Search(net.ParseIP("1.2.3.4")); meta.City() != "广东省" || meta.Country() != "" {
+		t.Errorf("unexpected mapped meta %s", meta)
+	}
+	if _, err = ReadXDB(data, Options{Fields: map[string]string{"town": "city"}}); err == nil {
+		t.Error("read with an unknown column got no error")
+	}
+	if _, err = ReadXDB(data[:100], Options{}); err == nil {
+		t.Error("read truncated xdb got no error")
+	}
+}
+
+func TestReadGeoLite2CSV(t *testing.T) {
+	blocks := "network,geoname_id,registered_country_geoname_id,represented_country_geoname_id," +
+		"is_anonymous_proxy,is_satellite_provider,postal_code,latitude,longitude,accuracy_radius\n" +
+		"1.0.0.0/24,1809858,1814991,,0,0,,23.1167,113.25,50\n" +
+		"1.0.1.0/24,,1814991,,0,0,,,,\n" +
+		"2.0.0.0/8,6252001,6252001,,0,0,,,,\n"
+	locations := "geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name," +
+		"subdivision_1_iso_code,subdivision_1_name,subdivision_2_iso_code,subdivision_2_name,city_name," +
+		"metro_code,time_zone,is_in_european_union\n" +
+		"1809858,zh-CN,AS,亚洲,CN,中国,GD,广东,,,广州,,Asia/Shanghai,0\n" +
+		"1814991,zh-CN,AS,亚洲,CN,中国,,,,,,,Asia/Shanghai,0\n" +
+		"6252001,zh-CN,NA,北美洲,US,美国,,,,,,,,0\n"
+	store, err := ReadGeoLite2CSV(strings.NewReader(blocks), strings.NewReader(locations), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]string{
+		"1.0.0.1": "中国广东广州CN0",
+		"1.0.1.1": "中国CN0",
+		"1.0.2.1": "0",
+		"2.1.1.1": "美国US0",
+	} {
+		meta := store.Search(net.ParseIP(addr))
+		if got := meta.Country() + meta.Province() + meta.City() + meta.ISOCode() + strconv.Itoa(meta.CountryCode()); got != want {
+			t.Errorf("search %s got %q, want %q", addr, got, want)
+		}
+	}
+
+	asn := "network,autonomous_system_number,autonomous_system_organization\n" +
+		"2400::/12,4134,CHINANET\n"
+	store, err = ReadGeoLite2CSV(strings.NewReader(asn), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Header().Mode() != provider.DataModeIPv6 || store.Search(net.ParseIP("::1")).ISP() != "" ||
+		store.Search(net.ParseIP("2401::")).ISP() != "CHINANET" {
+		t.Errorf("unexpected ASN store %s", store.Header())
+	}
+	if _, err = ReadGeoLite2CSV(strings.NewReader("network\n1.0.0.0/33\n"), nil, Options{}); err == nil {
+		t.Error("read invalid network got no error")
+	}
+}
+
+func TestReadIP2LocationCSV(t *testing.T) {
+	ipv4 := `"0","16777215","-","-","-","-"
+"16777216","16777471","CN","China","Guangdong","Guangzhou"
+"16777472","4294967295","US","United States of America","California","Los Angeles"
+`
+	store, err := ReadIP2LocationCSV(strings.NewReader(ipv4), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Header().Mode() != provider.DataModeIPv4 || store.EntityCount() != 3 {
+		t.Fatalf("unexpected store %s", store.Header())
+	}
+	if meta := store.Search(net.ParseIP("1.0.0.1")); meta.City() != "Guangzhou" || meta.CountryCode() != 0 || meta.ISOCode() != "CN" {
+		t.Errorf("unexpected meta %s", meta)
+	}
+	if meta := store.Search(net.ParseIP("0.0.0.1")); !meta.IsEmpty() {
+		t.Errorf("unexpected meta %s", meta)
+	}
+
+	ipv6 := `"0","47852207848256971424537054170092404735","-","-"
+"47852207848256971424537054170092404736","47935284597993528666593542111359926271","CN","China"
+`
+	store, err = ReadIP2LocationCSV(strings.NewReader(ipv6), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := store.Search(net.ParseIP("2401::")); meta.Country() != "China" {
+		t.Errorf("unexpected meta %s of %s", meta, store.Header())
+	}
+	if _, err = ReadIP2LocationCSV(strings.NewReader(`"2","1","CN","China"`), Options{}); err == nil {
+		t.Error("read invalid range got no error")
+	}
+	if _, err = ReadIP2LocationCSV(strings.NewReader(ipv4), Options{Fields: map[string]string{provider.ColumnISP: "isp"}}); err == nil {
+		t.Error("read with an unknown source field got no error")
+	}
+	db2 := `"16777216","16777471","CN","China","China Telecom"` + "\n"
+	store, err = ReadIP2LocationCSV(strings.NewReader(db2), Options{
+		Columns: []string{"ip_from", "ip_to", "country_code", "country_name", "isp"},
+		Fields:  map[string]string{provider.ColumnCountry: "country_name", provider.ColumnISP: "isp"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := store.Search(net.ParseIP("1.0.0.1")); meta.ISP() != "China Telecom" {
+		t.Errorf("unexpected meta %s", meta)
+	}
+}
@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"math"
+	"math/big"
+	"net/netip"
+)
+
+// IP2LocationColumns are the default columns of the IP2Location DB1, DB3, DB5, DB9 and DB11 CSV files,
+// the shorter files leave the trailing columns empty. The files with an isp column, e.g. DB2 and DB4,
+// need their own columns and fields.
+var IP2LocationColumns = []string{
+	"ip_from", "ip_to", "country_code", "country_name", "region_name", "city_name",
+	"latitude", "longitude", "zip_code", "time_zone",
+}
+
+// IP2LocationFields are the default fields of the IP2Location records.
+var IP2LocationFields = map[string]string{
+	provider.ColumnCountry:  "country_name",
+	provider.ColumnProvince: "region_name",
+	provider.ColumnCity:     "city_name",
+}
+
+// ReadIP2LocationCSV reads the IP2Location CSV file without header into a store, the "-" values are empty.
+// The ip_from and ip_to columns hold decimal addresses, the file is IPv6 if any of them exceeds 32 bits.
+func ReadIP2LocationCSV(reader io.Reader, options Options) (*provider.Store, error) {
+	options, err := options.withDefaults(IP2LocationColumns, IP2LocationFields)
+	if err != nil {
+		return nil, err
+	}
+	type row struct {
+		first, last *big.Int
+		meta        *provider.Meta
+	}
+	var rows []row
+	ipv6 := false
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		values, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := record(options.Columns, values, "-")
+		first, ok := new(big.Int).SetString(fields["ip_from"], 10)
+		last, ok2 := new(big.Int).SetString(fields["ip_to"], 10)
+		if !ok || !ok2 || first.Sign() < 0 || first.Cmp(last) > 0 || last.BitLen() > 128 {
+			return nil, fmt.Errorf("line %d, invalid range %q-%q", line, fields["ip_from"], fields["ip_to"])
+		}
+		ipv6 = ipv6 || last.BitLen() > 32
+		rows = append(rows, row{first, last, newMeta(options.Fields, fields)})
+	}
+
+	mode := provider.DataModeIPv4
+	if ipv6 {
+		mode = provider.DataModeIPv6
+	}
+	builder := provider.NewStoreBuilder(mode)
+	for _, row := range rows {
+		if err = builder.Add(provider.Range{First: bigAddr(row.first, ipv6), Last: bigAddr(row.last, ipv6)}, row.meta); err != nil {
+			return nil, err
+		}
+	}
+	return builder.Build(), nil
+}
+
+// bigAddr returns the IPv4 or IPv6 address of the decimal address.
+func bigAddr(v *big.Int, ipv6 bool) netip.Addr {
+	if !ipv6 && v.IsUint64() && v.Uint64() <= math.MaxUint32 {
+		return uint32Addr(uint32(v.Uint64()))
+	}
+	var b [16]byte
+	v.FillBytes(b[:])
+	return netip.AddrFrom16(b)
+}
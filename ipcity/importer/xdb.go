@@ -0,0 +1,74 @@
+package importer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"net/netip"
+	"strings"
+)
+
+const (
+	// xdbHeaderLength is the length of the xdb header before the vector index.
+	xdbHeaderLength = 256
+	// xdbSegmentIndexSize is the length of a segment index of start ip, end ip, data length and data pointer.
+	xdbSegmentIndexSize = 14
+)
+
+// XDBColumns are the default columns of the "|" separated xdb regions.
+var XDBColumns = []string{"country", "region", "province", "city", "isp"}
+
+// XDBFields are the default fields of the xdb regions.
+var XDBFields = map[string]string{
+	provider.ColumnCountry:  "country",
+	provider.ColumnProvince: "province",
+	provider.ColumnCity:     "city",
+	provider.ColumnISP:      "isp",
+}
+
+// ReadXDB reads the ip2region xdb data of version 2 into an IPv4 store, the "0" regions are empty.
+func ReadXDB(data []byte, options Options) (*provider.Store, error) {
+	options, err := options.withDefaults(XDBColumns, XDBFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < xdbHeaderLength {
+		return nil, fmt.Errorf("xdb header is truncated")
+	}
+	if version := binary.LittleEndian.Uint16(data); version != 2 {
+		return nil, fmt.Errorf("unsupported xdb version %d", version)
+	}
+	createdAt := binary.LittleEndian.Uint32(data[4:])
+	start, end := binary.LittleEndian.Uint32(data[8:]), binary.LittleEndian.Uint32(data[12:])
+	if start > end || uint64(end)+xdbSegmentIndexSize > uint64(len(data)) || (end-start)%xdbSegmentIndexSize != 0 {
+		return nil, fmt.Errorf("invalid xdb segment index %d-%d", start, end)
+	}
+
+	builder := provider.NewStoreBuilder(provider.DataModeIPv4).WithSourceUpdatedTime(int64(createdAt))
+	metas := make(map[uint32]*provider.Meta)
+	for p := start; p <= end; p += xdbSegmentIndexSize {
+		segment := data[p : p+xdbSegmentIndexSize]
+		first, last := binary.LittleEndian.Uint32(segment), binary.LittleEndian.Uint32(segment[4:])
+		length, ptr := binary.LittleEndian.Uint16(segment[8:]), binary.LittleEndian.Uint32(segment[10:])
+		if uint64(ptr)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("xdb region of segment %d is out of the data", (p-start)/xdbSegmentIndexSize)
+		}
+		meta, ok := metas[ptr]
+		if !ok {
+			region := strings.Split(string(data[ptr:ptr+uint32(length)]), "|")
+			meta = newMeta(options.Fields, record(options.Columns, region, "0"))
+			metas[ptr] = meta
+		}
+		r := provider.Range{First: uint32Addr(first), Last: uint32Addr(last)}
+		if err = builder.Add(r, meta); err != nil {
+			return nil, err
+		}
+	}
+	return builder.Build(), nil
+}
+
+func uint32Addr(v uint32) netip.Addr {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return netip.AddrFrom4(b)
+}
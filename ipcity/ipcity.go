@@ -2,15 +2,15 @@ package ipcity
 
 import (
 	"github.com/OVINC-CN/IPCity/ipcity/provider"
-	"net"
-	"os"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
-var (
-	stores = make([]*Store, 0, 16)
-	mutex  sync.Mutex
-)
+var defaultClient = NewClient()
 
 // Store exports provider.Store.
 type Store = provider.Store
@@ -24,43 +24,22 @@ type Meta = provider.Meta
 // Entity exports provider.Entity.
 type Entity = provider.Entity
 
+// Range exports provider.Range.
+type Range = provider.Range
+
 // Load IPCity data file.
 func Load(filename string) error {
-	var err error
-
-	// open file by filename
-	var fileReader *os.File
-	if fileReader, err = os.Open(filename); err != nil {
-		return err
-	}
-	defer func() { _ = fileReader.Close() }()
-
-	// load store from file reader
-	store := &Store{}
-	if err = store.UnmarshalFrom(fileReader); err != nil {
-		return err
-	}
-
-	// append store list
-	func() {
-		mutex.Lock()
-		defer mutex.Unlock()
-		stores = append(stores, store)
-	}()
-
-	return nil
+	return defaultClient.Load(filename)
 }
 
 // Search meta by address .
 func Search(addr string) *Meta {
-	var meta *Meta
-	for _, v := range stores {
-		meta = v.Search(net.ParseIP(addr))
-		if meta != nil && !meta.IsEmpty() {
-			break
-		}
-	}
-	return meta
+	return defaultClient.Search(addr)
+}
+
+// SearchAddr meta by parsed address.
+func SearchAddr(addr netip.Addr) *Meta {
+	return defaultClient.SearchAddr(addr)
 }
 
 // ClientInterface 用于查询ip归属地信息的接口
@@ -69,42 +48,202 @@ type ClientInterface interface {
 	Search(addr string) *Meta
 }
 
+// Client 可以在查询的同时并发加载ip信息库
 type Client struct {
-	stores []*Store
+	// mutex 保证加载串行执行
+	mutex sync.Mutex
+	// stores 是只读的ip信息库快照, 加载时整体替换
+	stores atomic.Pointer[storeSet]
+	// sources 记录可重新加载的ip信息库来源, 由mutex保护
+	sources map[*Store]source
+	// overlayFiles 是本地覆盖文件列表, 由mutex保护
+	overlayFiles []string
+	// reloadMutex 保证重新加载串行执行
+	reloadMutex sync.Mutex
+	// httpClient 用于从URL加载ip信息库
+	httpClient *http.Client
+	// policy 多个ip信息库的查询策略
+	policy Policy
+	// special 是否识别特殊用途地址段
+	special bool
+	// logger 用于报告加载时发现的数据问题, nil时不报告
+	logger *log.Logger
+	// strict 是否严格校验加载的ip信息库
+	strict bool
+}
+
+// storeSet 是按加载顺序排列的ip信息库, 按数据模式分组的索引, 以及本地覆盖
+type storeSet struct {
+	all     []*Store
+	byMode  map[provider.DataMode][]*Store
+	overlay *Overlay
+	// asnIndex 是按自治系统号索引的路由前缀, 首次查询时生成
+	asnOnce  sync.Once
+	asnIndex map[int][]ASN
+	// stats 是与all一一对应的统计, 首次查询时生成
+	statsOnce sync.Once
+	stats     []*provider.StoreStats
+}
+
+func newStoreSet(stores []*Store, overlay *Overlay) *storeSet {
+	set := &storeSet{all: stores, byMode: make(map[provider.DataMode][]*Store), overlay: overlay}
+	for _, store := range stores {
+		mode := store.Header().Mode()
+		set.byMode[mode] = append(set.byMode[mode], store)
+	}
+	return set
+}
+
+// Result 查询结果
+type Result struct {
+	// Meta 命中的ip信息
+	Meta *Meta
+	// Addr 实际查询的地址, 内嵌IPv4时为提取出的IPv4地址
+	Addr netip.Addr
+	// Translation 查询前对地址做的转换
+	Translation Translation
+	// Range 命中的地址段
+	Range Range
+	// Store 提供ip信息的ip信息库
+	Store *Store
+	// Header 提供ip信息的ip信息库的文件头
+	Header *Header
+	// FieldStores 按字段合并时每个字段的来源ip信息库
+	FieldStores map[string]*Store
+	// Overlay 命中本地覆盖时为true, 此时Range为覆盖的地址段
+	Overlay bool
+	// Scope 开启特殊用途地址识别时的地址分类, 特殊用途地址不返回Meta
+	Scope Scope
 }
 
 // Load 加载ip信息库
 func (c *Client) Load(filename string) error {
-	var err error
-	// open file by filename
-	var fileReader *os.File
-	if fileReader, err = os.Open(filename); err != nil {
+	store, _, err := fileSource(filename).load(c)
+	if err != nil {
 		return err
 	}
-	defer func() { _ = fileReader.Close() }()
-	// load store from file reader
-	store := &Store{}
-	if err = store.UnmarshalFrom(fileReader); err != nil {
-		return err
+	c.add(store, fileSource(filename))
+	return nil
+}
+
+// Stores 返回当前ip信息库快照, 调用方不可修改
+func (c *Client) Stores() []*Store {
+	if set := c.stores.Load(); set != nil {
+		return set.all[:len(set.all):len(set.all)]
 	}
-	// append store list
-	c.stores = append(c.stores, store)
 	return nil
 }
 
+// Stats 返回已加载的ip信息库及与其一一对应的统计, 每次加载后只统计一次, 统计结果共享不可修改
+func (c *Client) Stats() ([]*Store, []*provider.StoreStats) {
+	set := c.stores.Load()
+	if set == nil {
+		return nil, nil
+	}
+	set.statsOnce.Do(func() {
+		set.stats = make([]*provider.StoreStats, 0, len(set.all))
+		for _, store := range set.all {
+			set.stats = append(set.stats, store.Stats())
+		}
+	})
+	return set.all[:len(set.all):len(set.all)], set.stats[:len(set.stats):len(set.stats)]
+}
+
 // Search 查询ip信息
 func (c *Client) Search(addr string) *Meta {
-	var meta *Meta
-	for _, v := range c.stores {
-		meta = v.Search(net.ParseIP(addr))
-		if meta != nil && !meta.IsEmpty() {
-			break
+	result, _ := c.Lookup(addr)
+	return result.Meta
+}
+
+// SearchAddr 查询已解析的ip信息, 不产生内存分配
+func (c *Client) SearchAddr(addr netip.Addr) *Meta {
+	result, _ := c.LookupAddr(addr)
+	return result.Meta
+}
+
+// SearchResult 按地址族选择ip信息库查询, 内嵌IPv4的IPv6地址优先按IPv4查询
+func (c *Client) SearchResult(addr netip.Addr) Result {
+	result := c.search(addr)
+	if c.special && result.Scope == ScopeUnclassified {
+		result.Scope = ScopeGlobal
+	}
+	result.Header = result.Store.Header()
+	return result
+}
+
+var emptyStoreSet = &storeSet{}
+
+// search 依次查询本地覆盖, 特殊用途地址段和ip信息库
+func (c *Client) search(addr netip.Addr) Result {
+	set := c.stores.Load()
+	if set == nil {
+		set = emptyStoreSet
+	}
+	if result, ok := set.overlay.search(addr); ok {
+		return result
+	}
+	if ipv4, translation := EmbeddedIPv4(addr); translation != TranslationNone {
+		result, ok := set.overlay.search(ipv4)
+		if !ok {
+			result, ok = c.classify(ipv4)
+		}
+		if !ok {
+			result = c.resolve(set.byMode[provider.DataModeIPv4], ipv4)
 		}
+		if ok || !result.Meta.IsEmpty() {
+			result.Translation = translation
+			return result
+		}
+	}
+	if result, ok := c.classify(addr); ok {
+		return result
+	}
+	mode := provider.DataModeIPv6
+	if addr.Is4() {
+		mode = provider.DataModeIPv4
 	}
-	return meta
+	return c.resolve(set.byMode[mode], addr)
 }
 
 // NewClient 生成client对象
 func NewClient() *Client {
 	return &Client{}
 }
+
+// WithStrict 设置是否严格校验加载的ip信息库, 开启后列数, UTF-8编码或数值范围错误的数据加载失败
+func (c *Client) WithStrict(strict bool) *Client {
+	if c != nil {
+		c.strict = strict
+	}
+	return c
+}
+
+// WithLogger 设置报告加载时数据问题的logger, 如无法映射到ISO 3166代码的国家
+func (c *Client) WithLogger(logger *log.Logger) *Client {
+	if c != nil {
+		c.logger = logger
+	}
+	return c
+}
+
+// report 报告ip信息库中无法映射到ISO 3166代码的国家
+func (c *Client) report(store *Store) {
+	if c.logger == nil {
+		return
+	}
+	if mode := store.Header().Mode(); mode != provider.DataModeIPv4 && mode != provider.DataModeIPv6 {
+		return
+	}
+	if names := store.UnmappedCountries(); len(names) > 0 {
+		c.logger.Printf("ipcity: %d countries of %s data are not mapped to ISO 3166 codes: %s",
+			len(names), store.Header().ModeName(), strings.Join(names, ", "))
+	}
+}
+
+// WithHTTPClient 设置从URL加载ip信息库使用的http client
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	if c != nil {
+		c.httpClient = httpClient
+	}
+	return c
+}
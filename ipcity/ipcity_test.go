@@ -0,0 +1,690 @@
+package ipcity
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRow is a range start in ip index form and the meta of the range.
+type testRow struct {
+	ipIndex uint64
+	meta    *Meta
+}
+
+// encodeTestStore encodes rows as an ipcity data file.
+func encodeTestStore(t testing.TB, mode provider.DataMode, rows []testRow) []byte {
+	t.Helper()
+	header := provider.NewHeader(provider.DataVersionLatest, mode).
+		WithMetaRowCount(uint32(len(rows))).
+		WithEntityCount(uint32(len(rows))).
+		WithSchema(rows[0].meta.Schema())
+	if mode == provider.DataModeASN {
+		header.WithIPIndexSize(4)
+	}
+	buffer := &bytes.Buffer{}
+	if _, err := header.MarshalTo(buffer); err != nil {
+		t.Fatal(err)
+	}
+	metaMarshaler := &provider.MetaMarshaler{DataVersion: header.Version()}
+	for _, row := range rows {
+		if _, err := metaMarshaler.MarshalTo(row.meta, buffer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	marshaler := &provider.EntityMarshaler{
+		IPIndexSize:      header.IPIndexSize(),
+		MetaRowIndexSize: header.MetaRowIndexSize(),
+	}
+	for i, row := range rows {
+		if _, err := marshaler.MarshalTo(provider.NewEntity(row.ipIndex, uint32(i)), buffer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buffer.Bytes()
+}
+
+// writeTestStore writes rows as an ipcity data file and returns its path.
+func writeTestStore(t testing.TB, mode provider.DataMode, rows []testRow) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), fmt.Sprintf("%s.dat", provider.DataModeName[mode]))
+	if err := os.WriteFile(filename, encodeTestStore(t, mode, rows), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+var (
+	testIPv4Rows = []testRow{
+		{0x00000000, provider.NewMeta()},
+		{0x01000000, provider.NewMeta().WithCountry("中国").WithProvince("广东").WithCity("广州").WithISP("电信")},
+		{0x02000000, provider.NewMeta().WithCountry("美国").WithCountryCode(1)},
+		{0x03000000, provider.NewMeta()},
+	}
+	testIPv6Rows = []testRow{
+		{0x0000000000000000, provider.NewMeta()},
+		{0x2400000000000000, provider.NewMeta().WithCountry("中国").WithCity("北京")},
+		{0x2500000000000000, provider.NewMeta()},
+	}
+)
+
+func TestClientSearch(t *testing.T) {
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv6, testIPv6Rows)); err != nil {
+		t.Fatal(err)
+	}
+	for addr, city := range map[string]string{
+		"1.2.3.4":     "广州",
+		"2.0.0.1":     "",
+		"2400:da00::": "北京",
+	} {
+		if meta := client.Search(addr); meta.City() != city {
+			t.Errorf("search %s got city %q, want %q", addr, meta.City(), city)
+		}
+	}
+}
+
+func TestClientConcurrentLoadAndSearch(t *testing.T) {
+	filename := writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)
+	client := NewClient()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 16; j++ {
+				if err := client.Load(filename); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1024; j++ {
+				if meta := client.Search("1.2.3.4"); meta != nil && meta.City() != "广州" {
+					t.Errorf("unexpected meta %s", meta)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(client.Stores()); n != 64 {
+		t.Errorf("got %d stores, want 64", n)
+	}
+}
+
+func TestConcurrentLoadAndSearch(t *testing.T) {
+	filename := writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := Load(filename); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1024; j++ {
+				Search("1.2.3.4")
+			}
+		}()
+	}
+	wg.Wait()
+	if meta := Search("1.2.3.4"); meta.City() != "广州" {
+		t.Errorf("unexpected meta %s", meta)
+	}
+}
+
+func newBenchmarkClient(b *testing.B) *Client {
+	client := NewClient()
+	if err := client.Load(writeTestStore(b, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		b.Fatal(err)
+	}
+	if err := client.Load(writeTestStore(b, provider.DataModeIPv6, testIPv6Rows)); err != nil {
+		b.Fatal(err)
+	}
+	return client
+}
+
+func BenchmarkClientSearch(b *testing.B) {
+	client := newBenchmarkClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.Search("1.2.3.4")
+	}
+}
+
+func BenchmarkClientSearchAddr(b *testing.B) {
+	client := newBenchmarkClient(b)
+	addr := netip.MustParseAddr("2400:da00::1")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.SearchAddr(addr)
+	}
+}
+
+func TestClientSearchAddrAllocs(t *testing.T) {
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		t.Fatal(err)
+	}
+	addr := netip.MustParseAddr("1.2.3.4")
+	if n := testing.AllocsPerRun(100, func() { client.SearchAddr(addr) }); n != 0 {
+		t.Errorf("SearchAddr allocates %v times per run", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { client.Search("1.2.3.4") }); n != 0 {
+		t.Errorf("Search allocates %v times per run", n)
+	}
+}
+
+func TestClientSearchResultTranslation(t *testing.T) {
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv6, testIPv6Rows)); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]struct {
+		city        string
+		translation Translation
+	}{
+		"1.2.3.4":                     {"广州", TranslationNone},
+		"::ffff:1.2.3.4":              {"广州", TranslationIPv4Mapped},
+		"2002:0102:0304::1":           {"广州", Translation6to4},
+		"2001:0:4136:e378::fefd:fcfb": {"广州", TranslationTeredo},
+		"64:ff9b::1.2.3.4":            {"广州", TranslationNAT64},
+		"2400:da00::1":                {"北京", TranslationNone},
+	} {
+		result := client.SearchResult(netip.MustParseAddr(addr))
+		if result.Meta.City() != want.city || result.Translation != want.translation {
+			t.Errorf("search %s got (%q, %s), want (%q, %s)",
+				addr, result.Meta.City(), result.Translation, want.city, want.translation)
+		}
+	}
+}
+
+func TestClientPolicy(t *testing.T) {
+	coarse := writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta().WithCountry("中国").WithProvince("广东").WithISP("电信")},
+	})
+	fine := writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta()},
+		{0x01020300, provider.NewMeta().WithCountry("中国").WithProvince("广东").WithCity("深圳")},
+		{0x01020400, provider.NewMeta()},
+	})
+	addr := netip.MustParseAddr("1.2.3.4")
+
+	for policy, want := range map[Policy]string{
+		PolicyFirstMatch:   "country:\"中国\" province:\"广东\" city:\"\"",
+		PolicyMostSpecific: "country:\"中国\" province:\"广东\" city:\"深圳\"",
+		PolicyFieldMerge:   "country:\"中国\" province:\"广东\" city:\"深圳\"",
+	} {
+		client := NewClient().WithPolicy(policy)
+		for _, filename := range []string{coarse, fine} {
+			if err := client.Load(filename); err != nil {
+				t.Fatal(err)
+			}
+		}
+		result := client.SearchResult(addr)
+		if got := result.Meta.String(); got[:len(want)] != want {
+			t.Errorf("policy %s got %s, want %s", policy, got, want)
+		}
+		if policy == PolicyFieldMerge {
+			stores := client.Stores()
+			if result.FieldStores[FieldCity] != stores[1] || result.FieldStores[FieldISP] != stores[0] {
+				t.Errorf("policy %s got unexpected field stores", policy)
+			}
+			if result.Meta.ISP() != "电信" {
+				t.Errorf("policy %s got isp %q", policy, result.Meta.ISP())
+			}
+		}
+	}
+}
+
+func TestClientSpecialBlocks(t *testing.T) {
+	client := NewClient().WithSpecialBlocks(true)
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		t.Fatal(err)
+	}
+	for addr, scope := range map[string]Scope{
+		"1.2.3.4":            ScopeGlobal,
+		"10.1.2.3":           ScopePrivate,
+		"100.64.0.1":         ScopeShared,
+		"127.0.0.1":          ScopeLoopback,
+		"192.0.2.1":          ScopeDocumentation,
+		"224.0.0.1":          ScopeMulticast,
+		"::ffff:192.168.1.1": ScopePrivate,
+		"fd00::1":            ScopeUniqueLocal,
+		"fe80::1":            ScopeLinkLocal,
+		"2001:db8::1":        ScopeDocumentation,
+	} {
+		result := client.SearchResult(netip.MustParseAddr(addr))
+		if result.Scope != scope {
+			t.Errorf("search %s got scope %s, want %s", addr, result.Scope, scope)
+		}
+		if scope != ScopeGlobal && result.Meta != nil {
+			t.Errorf("search %s got meta %s for special block", addr, result.Meta)
+		}
+	}
+	if result := NewClient().SearchResult(netip.MustParseAddr("10.1.2.3")); result.Scope != ScopeUnclassified {
+		t.Errorf("got scope %s with special blocks disabled", result.Scope)
+	}
+}
+
+func TestClientLookup(t *testing.T) {
+	client := NewClient().WithSpecialBlocks(true)
+	if _, err := client.Lookup("1.2.3.4"); !errors.Is(err, ErrNoStoreForFamily) {
+		t.Errorf("got error %v, want %v", err, ErrNoStoreForFamily)
+	}
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]error{
+		"1.2.3.4":     nil,
+		"3.0.0.1":     ErrNotFound,
+		"10.0.0.1":    nil,
+		"2400:da00::": ErrNoStoreForFamily,
+		"1.2.3":       ErrInvalidAddress,
+	} {
+		if _, err := client.Lookup(addr); !errors.Is(err, want) {
+			t.Errorf("lookup %s got error %v, want %v", addr, err, want)
+		}
+	}
+	result, err := client.Lookup("1.2.3.4")
+	if err != nil || result.Header.Mode() != provider.DataModeIPv4 || result.Range.String() != "1.0.0.0-1.255.255.255" {
+		t.Errorf("unexpected result %+v, %v", result, err)
+	}
+}
+
+func TestClientSearchSchema(t *testing.T) {
+	schema := provider.DefaultSchema.WithColumns(
+		provider.Column{Name: "continent", Type: provider.ColumnTypeString},
+		provider.Column{Name: "population", Type: provider.ColumnTypeInt},
+	)
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta().WithSchema(schema)},
+		{0x01000000, provider.NewMeta().WithSchema(schema).WithCity("广州").
+			WithField("continent", "亚洲").WithField("population", 18676605)},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	meta := client.Search("1.2.3.4")
+	if meta.Get("continent") != "亚洲" || meta.Get("population") != 18676605 || meta.City() != "广州" {
+		t.Errorf("unexpected meta %s", meta)
+	}
+	if fields := meta.Fields(); len(fields) != 10 || fields[9].Name != "population" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if meta = client.Search("0.0.0.1"); meta.Get("population") != 0 {
+		t.Errorf("unexpected empty meta %s", meta)
+	}
+}
+
+func TestMetaLocalized(t *testing.T) {
+	schema := provider.DefaultSchema.WithColumns(provider.LocalizedColumns("en", "zh-Hant")...)
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta().WithSchema(schema)},
+		{0x01000000, provider.NewMeta().WithSchema(schema).WithCountry("中国").WithCity("广州").
+			WithLocalized(provider.ColumnCountry, "en", "China").WithLocalized(provider.ColumnCity, "en", "Guangzhou").
+			WithLocalized(provider.ColumnCity, "zh-Hant", "廣州")},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	meta := client.Search("1.2.3.4")
+	for _, c := range []struct {
+		langs         []string
+		country, city string
+	}{
+		{nil, "中国", "广州"},
+		{[]string{"en-US"}, "China", "Guangzhou"},
+		{[]string{"zh_Hant_TW", "en"}, "China", "廣州"},
+		{[]string{"fr"}, "中国", "广州"},
+	} {
+		localized := meta.Localized(c.langs...)
+		if localized.Country() != c.country || localized.City() != c.city {
+			t.Errorf("unexpected localized meta %v %s", c.langs, localized)
+		}
+	}
+	if meta.City() != "广州" {
+		t.Errorf("localized changed the meta %s", meta)
+	}
+}
+
+func TestMetaISOCode(t *testing.T) {
+	for _, c := range []struct {
+		meta *Meta
+		code string
+	}{
+		{provider.NewMeta().WithCountry("中国").WithProvince("广东"), "CN"},
+		{provider.NewMeta().WithCountry("中国").WithProvince("香港"), "HK"},
+		{provider.NewMeta().WithCountry("United States"), "US"},
+		{provider.NewMeta().WithCountry("JP").WithCountryCode(81), "JP"},
+		// the country code is a dialing code, 86 is not a numeric iso code
+		{provider.NewMeta().WithCountryCode(86), ""},
+		{provider.NewMeta().WithCountry("内网IP"), ""},
+	} {
+		if code := c.meta.ISOCode(); code != c.code {
+			t.Errorf("unexpected iso code %q of %s", code, c.meta)
+		}
+	}
+	if country, ok := provider.ISOCountryByName("DEU"); !ok || country.Alpha2 != "DE" || country.Numeric != 276 {
+		t.Errorf("unexpected country %v", country)
+	}
+
+	var buffer bytes.Buffer
+	client := NewClient().WithLogger(log.New(&buffer, "", 0))
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta().WithCountry("内网IP")},
+		{0x01000000, provider.NewMeta().WithCountry("中国").WithCity("广州")},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), "内网IP") || strings.Contains(buffer.String(), "中国") {
+		t.Errorf("unexpected report %q", buffer.String())
+	}
+}
+
+func TestClientStrict(t *testing.T) {
+	data := encodeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta()},
+		{0x01000000, provider.NewMeta().WithCountry("中国")},
+		{0x02000000, provider.NewMeta().WithCountry("美国").WithCountryCode(1000)},
+	})
+	if err := NewClient().LoadBytes(data); err != nil {
+		t.Errorf("unexpected lenient load error, %s", err)
+	}
+	err := NewClient().WithStrict(true).LoadBytes(data)
+	var metaError *provider.MetaError
+	if !errors.As(err, &metaError) || metaError.Row != 2 {
+		t.Errorf("unexpected strict load error, %v", err)
+	}
+}
+
+func TestDivisions(t *testing.T) {
+	divisions := DefaultDivisions()
+	chain := divisions.Chain(110105)
+	if len(chain) != 2 || chain[0].Name != "北京市" || chain[1].Name != "朝阳区" || chain[1].ParentCode != 110100 {
+		t.Errorf("unexpected chain %v", chain)
+	}
+	if children := divisions.Children(110000); len(children) == 0 || children[0].Code != 110101 {
+		t.Errorf("unexpected children %v", children)
+	}
+
+	for code, want := range map[int][]string{
+		110108: {"北京市", "海淀区"},
+		130209: {"河北省", "唐山市", "曹妃甸区"},
+		320583: {"江苏省", "苏州市", "昆山市"},
+		440306: {"广东省", "深圳市", "宝安区"},
+		469001: {"海南省", "五指山市"},
+		500243: {"重庆市", "彭水苗族土家族自治县"},
+		510116: {"四川省", "成都市", "双流区"},
+		653131: {"新疆维吾尔自治区", "喀什地区", "塔什库尔干塔吉克自治县"},
+	} {
+		chain := divisions.Chain(code)
+		names := make([]string, 0, len(chain))
+		for _, division := range chain {
+			names = append(names, division.Name)
+		}
+		if strings.Join(names, "/") != strings.Join(want, "/") {
+			t.Errorf("unexpected chain of %d %v", code, names)
+		}
+	}
+
+	for _, c := range []struct {
+		meta  *Meta
+		valid bool
+	}{
+		{provider.NewMeta().WithProvince("江苏").WithCity("苏州").WithDistrict("昆山").WithAreaCode(320583), true},
+		{provider.NewMeta().WithProvince("广东").WithCity("广州").WithDistrict("天河").WithAreaCode(440106), true},
+		{provider.NewMeta().WithProvince("北京").WithCity("北京").WithDistrict("朝阳区").WithAreaCode(110105), true},
+		{provider.NewMeta().WithProvince("广东").WithCity("深圳").WithAreaCode(440106), false},
+		{provider.NewMeta().WithCity("广州").WithAreaCode(449999), false},
+		{provider.NewMeta().WithCity("广州"), true},
+	} {
+		if err := divisions.Validate(c.meta); (err == nil) != c.valid {
+			t.Errorf("unexpected validation of %s, %v", c.meta, err)
+		}
+	}
+
+	meta := provider.NewMeta().WithProvince("广东").WithCity("广州").WithDistrict("天河").WithAreaCode(440100)
+	if normalized := divisions.Normalize(meta); normalized.Province() != "广东省" ||
+		normalized.City() != "广州市" || normalized.District() != "天河" || meta.Province() != "广东" {
+		t.Errorf("unexpected normalized meta %s", normalized)
+	}
+
+	full, err := ReadDivisions(strings.NewReader("440000,广东省\n440100,广州市\n440113,番禺区\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = full.Validate(provider.NewMeta().WithDistrict("番禺").WithAreaCode(440113)); err != nil {
+		t.Error(err)
+	}
+	store := provider.NewStore()
+	if err = store.UnmarshalBinary(encodeTestStore(t, provider.DataModeIPv4, append(testIPv4Rows,
+		testRow{0x04000000, provider.NewMeta().WithCity("深圳").WithAreaCode(440100)}))); err != nil {
+		t.Fatal(err)
+	}
+	if errs := divisions.ValidateStore(store); len(errs) != 1 || errs[4] == nil {
+		t.Errorf("unexpected store errors %v", errs)
+	}
+}
+
+func TestClientFindRanges(t *testing.T) {
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta()},
+		{0x01000000, provider.NewMeta().WithProvince("广东").WithCity("广州").WithISP("电信")},
+		{0x01800000, provider.NewMeta().WithProvince("广东").WithCity("广州").WithISP("移动")},
+		{0x01C00000, provider.NewMeta().WithProvince("广东").WithCity("广州").WithISP("电信")},
+		{0x02000000, provider.NewMeta().WithCountry("美国").WithCountryCode(1)},
+		{0x03000000, provider.NewMeta()},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv6, testIPv6Rows)); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		expressions []string
+		want        string
+	}{
+		{[]string{"city=广州"}, "[1.0.0.0/8]"},
+		{[]string{"province^=广", "isp=电信"}, "[1.0.0.0/9 1.192.0.0/10]"},
+		{[]string{"isp~=^移"}, "[1.128.0.0/10]"},
+		{[]string{"countryCode=1"}, "[2.0.0.0/8]"},
+		{[]string{"city=北京"}, "[2400::/8]"},
+		{[]string{"city=深圳"}, "[]"},
+	} {
+		filter, err := ParseFilter(c.expressions...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(client.FindRanges(filter).Prefixes()); got != c.want {
+			t.Errorf("unexpected ranges of %s %s, want %s", filter, got, c.want)
+		}
+	}
+	// the ranges resolve by the overlay and the policy like the searches
+	overlayFile := filepath.Join(t.TempDir(), "overlay.csv")
+	if err := os.WriteFile(overlayFile, []byte("cidr,country,city\n1.2.0.0/16,中国,深圳\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second := writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta()},
+		{0x02000000, provider.NewMeta().WithCity("广州")},
+		{0x02800000, provider.NewMeta()},
+		{0x03000000, provider.NewMeta().WithCity("广州")},
+		{0x04000000, provider.NewMeta()},
+	})
+	for policy, want := range map[Policy]string{
+		PolicyFirstMatch:   "[1.0.0.0/15 1.3.0.0/16 1.4.0.0/14 1.8.0.0/13 1.16.0.0/12 1.32.0.0/11 1.64.0.0/10 1.128.0.0/9 3.0.0.0/8]",
+		PolicyMostSpecific: "[1.0.0.0/15 1.3.0.0/16 1.4.0.0/14 1.8.0.0/13 1.16.0.0/12 1.32.0.0/11 1.64.0.0/10 1.128.0.0/9 2.0.0.0/9 3.0.0.0/8]",
+	} {
+		client = NewClient().WithPolicy(policy)
+		for _, filename := range []string{writeTestStore(t, provider.DataModeIPv4, testIPv4Rows), second} {
+			if err := client.Load(filename); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := client.LoadOverlay(overlayFile); err != nil {
+			t.Fatal(err)
+		}
+		filter, _ := ParseFilter("city=广州")
+		if got := fmt.Sprint(client.FindRanges(filter).Prefixes()); got != want {
+			t.Errorf("unexpected %s ranges %s, want %s", policy, got, want)
+		}
+		filter, _ = ParseFilter("city=深圳")
+		if got := fmt.Sprint(client.FindRanges(filter).Prefixes()); got != "[1.2.0.0/16]" {
+			t.Errorf("unexpected %s overlay ranges %s", policy, got)
+		}
+	}
+
+	client = NewClient().WithSpecialBlocks(true)
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta()},
+		{0x09000000, provider.NewMeta().WithCity("广州")},
+		{0x0B000000, provider.NewMeta()},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if filter, _ := ParseFilter("city=广州"); fmt.Sprint(client.FindRanges(filter).Prefixes()) != "[9.0.0.0/8]" {
+		t.Errorf("unexpected ranges with special blocks %s", client.FindRanges(filter).Prefixes())
+	}
+}
+
+func TestExport(t *testing.T) {
+	list := RangeList{
+		IPv4: []netip.Prefix{netip.MustParsePrefix("1.0.0.0/9"), netip.MustParsePrefix("1.128.0.0/9")},
+		IPv6: []netip.Prefix{netip.MustParsePrefix("2400::/8")},
+	}
+	for _, c := range []struct {
+		format ExportFormat
+		want   string
+	}{
+		{ExportCIDR, "1.0.0.0/8\n2400::/8\n"},
+		{ExportIPSet, "create gd hash:net family inet maxelem 65536 -exist\nadd gd 1.0.0.0/8 -exist\n" +
+			"create gd6 hash:net family inet6 maxelem 65536 -exist\nadd gd6 2400::/8 -exist\n"},
+		{ExportNFTables, "table inet gd {\n\tset gd_v4 {\n\t\ttype ipv4_addr\n\t\tflags interval\n" +
+			"\t\telements = {\n\t\t\t1.0.0.0/8\n\t\t}\n\t}\n\tset gd_v6 {\n\t\ttype ipv6_addr\n\t\tflags interval\n" +
+			"\t\telements = {\n\t\t\t2400::/8\n\t\t}\n\t}\n}\n"},
+		{ExportIPTables, "iptables -N gd 2>/dev/null || iptables -F gd\niptables -A gd -s 1.0.0.0/8 -j DROP\n" +
+			"ip6tables -N gd 2>/dev/null || ip6tables -F gd\nip6tables -A gd -s 2400::/8 -j DROP\n"},
+		{ExportNginx, "geo $gd {\n\tdefault 0;\n\t1.0.0.0/8 guangdong;\n\t2400::/8 guangdong;\n}\n"},
+		{ExportHAProxy, "1.0.0.0/8 guangdong\n2400::/8 guangdong\n"},
+	} {
+		buffer := &bytes.Buffer{}
+		if err := Export(buffer, c.format, list, ExportOptions{Name: "gd", Target: "DROP", Value: "guangdong"}); err != nil {
+			t.Fatal(err)
+		}
+		if buffer.String() != c.want {
+			t.Errorf("unexpected %s export %q, want %q", c.format, buffer, c.want)
+		}
+	}
+	if err := Export(&bytes.Buffer{}, ExportNginx, list, ExportOptions{Value: "1; }"}); err == nil {
+		t.Error("expected invalid value error")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, []testRow{
+		{0x00000000, provider.NewMeta().WithSchema(provider.LocationSchema)},
+		{0x01000000, provider.NewMeta().WithSchema(provider.LocationSchema).WithCity("北京").
+			WithLocation(39.9042, 116.4074).WithTimeZone("Asia/Shanghai").WithPostalCode("100000")},
+		{0x02000000, provider.NewMeta().WithSchema(provider.LocationSchema).WithCity("上海").
+			WithLocation(31.2304, 121.4737).WithAccuracyRadius(20)},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	beijing, _ := client.Lookup("1.1.1.1")
+	shanghai, _ := client.Lookup("2.2.2.2")
+	if beijing.Meta.TimeZone() != "Asia/Shanghai" || shanghai.Meta.AccuracyRadius() != 20 {
+		t.Errorf("unexpected meta %s, %s", beijing.Meta, shanghai.Meta)
+	}
+	if d, ok := Distance(beijing, shanghai); !ok || d < 1060 || d > 1075 {
+		t.Errorf("got distance %v, %v", d, ok)
+	}
+	empty, _ := client.Lookup("0.0.0.1")
+	if _, ok := Distance(beijing, empty); ok {
+		t.Errorf("got distance to a meta without location")
+	}
+}
+
+func TestClientSearchASN(t *testing.T) {
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Load(writeTestStore(t, provider.DataModeASN, []testRow{
+		{0x00000000, provider.NewMeta().WithSchema(provider.ASNSchema)},
+		{0x01020000, provider.NewMeta().WithSchema(provider.ASNSchema).WithASN(4134, "CHINANET", "")},
+		{0x01030000, provider.NewMeta().WithSchema(provider.ASNSchema).WithASN(4134, "CHINANET", "1.3.0.0/24")},
+		{0x01030100, provider.NewMeta().WithSchema(provider.ASNSchema)},
+		// 1.4.0.0-1.4.2.255 is not a single prefix
+		{0x01040000, provider.NewMeta().WithSchema(provider.ASNSchema).WithASN(4808, "CNCGROUP", "")},
+		{0x01040300, provider.NewMeta().WithSchema(provider.ASNSchema)},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if meta := client.Search("1.2.3.4"); meta.City() != "广州" {
+		t.Errorf("unexpected meta %s", meta)
+	}
+	asn := client.SearchASN("::ffff:1.2.3.4")
+	if asn == nil || asn.Number != 4134 || asn.Prefix.String() != "1.2.0.0/16" {
+		t.Errorf("unexpected asn %+v", asn)
+	}
+	if asn = client.SearchASN("1.3.1.1"); asn != nil {
+		t.Errorf("unexpected asn %+v", asn)
+	}
+	if asn = client.SearchASN("1.4.2.1"); asn == nil || asn.Number != 4808 || asn.Prefix.String() != "1.4.2.0/24" {
+		t.Errorf("unexpected asn %+v", asn)
+	}
+	if prefixes := client.ASNPrefixes(4134); len(prefixes) != 2 || prefixes[1].Prefix.String() != "1.3.0.0/24" {
+		t.Errorf("unexpected prefixes %+v", prefixes)
+	}
+	if prefixes := client.ASNPrefixes(4808); len(prefixes) != 2 ||
+		prefixes[0].Prefix.String() != "1.4.0.0/23" || prefixes[1].Prefix.String() != "1.4.2.0/24" {
+		t.Errorf("unexpected prefixes %+v", prefixes)
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		t.Fatal(err)
+	}
+	stores, cached := client.Stats()
+	if len(stores) != 1 || len(cached) != 1 || cached[0].Ranges != 2 {
+		t.Fatalf("unexpected client stats %v", cached)
+	}
+	if _, again := client.Stats(); again[0] != cached[0] {
+		t.Error("client stats are not cached")
+	}
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv6, testIPv6Rows)); err != nil {
+		t.Fatal(err)
+	}
+	if stores, reloaded := client.Stats(); len(stores) != 2 || len(reloaded) != 2 || reloaded[0] == cached[0] {
+		t.Errorf("client stats are not refreshed after a load %v", reloaded)
+	}
+}
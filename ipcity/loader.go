@@ -0,0 +1,345 @@
+package ipcity
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+var (
+	// gzipMagicNumber is the leading bytes of a gzip stream.
+	gzipMagicNumber = []byte{0x1f, 0x8b}
+	// zstdMagicNumber is the leading bytes of a zstd frame.
+	zstdMagicNumber = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress wraps the reader with a decompressor detected by the magic bytes.
+func decompress(reader io.Reader) (io.ReadCloser, error) {
+	ireader := bufio.NewReader(reader)
+	magic, _ := ireader.Peek(len(zstdMagicNumber))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagicNumber):
+		return gzip.NewReader(ireader)
+	case bytes.HasPrefix(magic, zstdMagicNumber):
+		decoder, err := zstd.NewReader(ireader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(ireader), nil
+	}
+}
+
+// readStore reads a plain, gzip or zstd compressed store from the reader.
+func (c *Client) readStore(reader io.Reader) (*Store, error) {
+	ireader, err := decompress(reader)
+	if err != nil {
+		return nil, fmt.Errorf("decompress data error, %s", err)
+	}
+	defer func() { _ = ireader.Close() }()
+	store := &Store{}
+	if err = (&provider.StoreUnmarshaler{Strict: c.strict}).UnmarshalFrom(ireader, store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// LoadReader IPCity data from a reader.
+func LoadReader(reader io.Reader) error {
+	return defaultClient.LoadReader(reader)
+}
+
+// LoadBytes IPCity data from a bytes buffer.
+func LoadBytes(data []byte) error {
+	return defaultClient.LoadBytes(data)
+}
+
+// LoadStore IPCity data from a built store.
+func LoadStore(store *Store) error {
+	return defaultClient.LoadStore(store)
+}
+
+// LoadFS IPCity data file from a file system.
+func LoadFS(fsys fs.FS, name string) error {
+	return defaultClient.LoadFS(fsys, name)
+}
+
+// LoadURL IPCity data from an HTTP(S) URL.
+func LoadURL(url string) error {
+	return defaultClient.LoadURL(url)
+}
+
+// LoadOverlay local override ranges from a CSV or YAML file.
+func LoadOverlay(filename string) error {
+	return defaultClient.LoadOverlay(filename)
+}
+
+// Reload IPCity data files, URLs and overlay files.
+func Reload() error {
+	return defaultClient.Reload()
+}
+
+// source 记录ip信息库的来源, 用于重新加载
+type source interface {
+	fmt.Stringer
+	// load 读取ip信息库及其新的来源, 来源未修改时返回nil
+	load(c *Client) (*Store, source, error)
+}
+
+// fileSource 是通过文件名加载的ip信息库
+type fileSource string
+
+func (f fileSource) String() string {
+	return string(f)
+}
+
+func (f fileSource) load(c *Client) (*Store, source, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = file.Close() }()
+	store, err := c.readStore(file)
+	return store, f, err
+}
+
+// fsSource 是从文件系统加载的ip信息库
+type fsSource struct {
+	fsys fs.FS
+	name string
+}
+
+func (f *fsSource) String() string {
+	return f.name
+}
+
+func (f *fsSource) load(c *Client) (*Store, source, error) {
+	file, err := f.fsys.Open(f.name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = file.Close() }()
+	store, err := c.readStore(file)
+	return store, f, err
+}
+
+// urlSource 是从URL加载的ip信息库及其最后修改时间
+type urlSource struct {
+	url          string
+	lastModified string
+}
+
+func (u *urlSource) String() string {
+	return u.url
+}
+
+func (u *urlSource) load(c *Client) (*Store, source, error) {
+	request, err := http.NewRequest(http.MethodGet, u.url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.lastModified != "" {
+		request.Header.Set("If-Modified-Since", u.lastModified)
+	}
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if u.lastModified != "" {
+			return nil, nil, nil
+		}
+		return nil, nil, errors.New("not modified response for a url never loaded")
+	default:
+		return nil, nil, fmt.Errorf("load %s error, %s", u.url, response.Status)
+	}
+	store, err := c.readStore(response.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, &urlSource{url: u.url, lastModified: response.Header.Get("Last-Modified")}, nil
+}
+
+// publish 在mutex保护下发布新快照
+func (c *Client) publish(stores []*Store, overlay *Overlay) {
+	c.stores.Store(newStoreSet(stores, overlay))
+}
+
+// add 发布追加了store的新快照, src为nil时不可重新加载
+func (c *Client) add(store *Store, src source) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if src != nil {
+		if c.sources == nil {
+			c.sources = make(map[*Store]source)
+		}
+		c.sources[store] = src
+	}
+	c.report(store)
+	c.publish(append(c.Stores(), store), c.overlay())
+}
+
+// overlay 返回当前快照的本地覆盖
+func (c *Client) overlay() *Overlay {
+	if set := c.stores.Load(); set != nil {
+		return set.overlay
+	}
+	return nil
+}
+
+// LoadReader 从reader加载ip信息库, 自动识别gzip和zstd压缩
+func (c *Client) LoadReader(reader io.Reader) error {
+	store, err := c.readStore(reader)
+	if err != nil {
+		return err
+	}
+	c.add(store, nil)
+	return nil
+}
+
+// LoadBytes 从内存加载ip信息库
+func (c *Client) LoadBytes(data []byte) error {
+	return c.LoadReader(bytes.NewReader(data))
+}
+
+// LoadStore 加载内存中构建的ip信息库, 如导入的第三方数据, 不可重新加载
+func (c *Client) LoadStore(store *Store) error {
+	if store == nil {
+		return fmt.Errorf("store is nil")
+	}
+	c.add(store, nil)
+	return nil
+}
+
+// LoadFS 从文件系统加载ip信息库
+func (c *Client) LoadFS(fsys fs.FS, name string) error {
+	src := &fsSource{fsys: fsys, name: name}
+	store, _, err := src.load(c)
+	if err != nil {
+		return err
+	}
+	c.add(store, src)
+	return nil
+}
+
+// LoadURL 从HTTP(S)地址加载ip信息库, 再次加载同一地址时带上If-Modified-Since, 未修改则保持不变
+func (c *Client) LoadURL(url string) error {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	var old *Store
+	var src source = &urlSource{url: url}
+	c.mutex.Lock()
+	for store, v := range c.sources {
+		if u, ok := v.(*urlSource); ok && u.url == url {
+			old, src = store, u
+			break
+		}
+	}
+	c.mutex.Unlock()
+
+	store, next, err := src.load(c)
+	if err != nil || store == nil {
+		return err
+	}
+	if old == nil {
+		c.add(store, next)
+		return nil
+	}
+	c.commit(map[*Store]*Store{old: store}, map[*Store]source{store: next}, nil, false)
+	return nil
+}
+
+// LoadOverlay 加载本地覆盖文件, 查询时优先于ip信息库, 支持CSV和YAML
+func (c *Client) LoadOverlay(filename string) error {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	c.mutex.Lock()
+	filenames := append(c.overlayFiles[:len(c.overlayFiles):len(c.overlayFiles)], filename)
+	c.mutex.Unlock()
+
+	overlay, err := LoadOverlayFiles(filenames...)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.overlayFiles = filenames
+	c.publish(c.Stores(), overlay)
+	return nil
+}
+
+// Reload 重新加载所有来自文件, 文件系统和URL的ip信息库以及本地覆盖文件, 全部成功后才整体替换
+func (c *Client) Reload() error {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	c.mutex.Lock()
+	sources := make(map[*Store]source, len(c.sources))
+	for store, src := range c.sources {
+		sources[store] = src
+	}
+	overlayFiles := c.overlayFiles
+	c.mutex.Unlock()
+
+	replaced := make(map[*Store]*Store, len(sources))
+	nextSources := make(map[*Store]source, len(sources))
+	for old, src := range sources {
+		store, next, err := src.load(c)
+		if err != nil {
+			return fmt.Errorf("reload %s error, %s", src, err)
+		}
+		if store != nil {
+			replaced[old] = store
+			nextSources[store] = next
+		}
+	}
+	var overlay *Overlay
+	if len(overlayFiles) > 0 {
+		var err error
+		if overlay, err = LoadOverlayFiles(overlayFiles...); err != nil {
+			return err
+		}
+	}
+
+	c.commit(replaced, nextSources, overlay, len(overlayFiles) > 0)
+	return nil
+}
+
+// commit 发布替换了ip信息库和本地覆盖的新快照
+func (c *Client) commit(replaced map[*Store]*Store, sources map[*Store]source, overlay *Overlay, withOverlay bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stores := append([]*Store(nil), c.Stores()...)
+	for i, store := range stores {
+		if next, ok := replaced[store]; ok {
+			stores[i] = next
+			c.report(next)
+			delete(c.sources, store)
+			c.sources[next] = sources[next]
+		}
+	}
+	if !withOverlay {
+		overlay = c.overlay()
+	}
+	c.publish(stores, overlay)
+}
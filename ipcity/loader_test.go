@@ -0,0 +1,90 @@
+package ipcity
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"github.com/klauspost/compress/zstd"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	_, _ = writer.Write(data)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func zstdBytes(t *testing.T, data []byte) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = encoder.Close() }()
+	return encoder.EncodeAll(data, nil)
+}
+
+func TestClientLoadCompressed(t *testing.T) {
+	data := encodeTestStore(t, provider.DataModeIPv4, testIPv4Rows)
+	for name, load := range map[string]func(*Client) error{
+		"bytes": func(c *Client) error { return c.LoadBytes(data) },
+		"gzip":  func(c *Client) error { return c.LoadBytes(gzipBytes(t, data)) },
+		"zstd":  func(c *Client) error { return c.LoadReader(bytes.NewReader(zstdBytes(t, data))) },
+		"fs": func(c *Client) error {
+			return c.LoadFS(fstest.MapFS{"ipv4.dat.gz": {Data: gzipBytes(t, data)}}, "ipv4.dat.gz")
+		},
+	} {
+		client := NewClient()
+		if err := load(client); err != nil {
+			t.Fatalf("load %s error, %s", name, err)
+		}
+		if meta := client.Search("1.2.3.4"); meta.City() != "广州" {
+			t.Errorf("load %s got meta %s", name, meta)
+		}
+	}
+}
+
+func TestClientLoadURL(t *testing.T) {
+	data := encodeTestStore(t, provider.DataModeIPv4, testIPv4Rows)
+	modified := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-Modified-Since") != "" {
+			notModified++
+		}
+		http.ServeContent(w, r, "ipv4.dat.zst", modified, bytes.NewReader(zstdBytes(t, data)))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	for i := 0; i < 3; i++ {
+		if err := client.LoadURL(server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 3 || notModified != 2 {
+		t.Errorf("got %d requests with %d conditional, want 3 with 2", requests, notModified)
+	}
+	if n := len(client.Stores()); n != 1 {
+		t.Errorf("got %d stores, want 1", n)
+	}
+	if meta := client.Search("1.2.3.4"); meta.City() != "广州" {
+		t.Errorf("unexpected meta %s", meta)
+	}
+
+	modified = modified.Add(time.Hour)
+	if err := client.LoadURL(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(client.Stores()); n != 1 {
+		t.Errorf("got %d stores after reload, want 1", n)
+	}
+}
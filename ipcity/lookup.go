@@ -0,0 +1,72 @@
+package ipcity
+
+import (
+	"errors"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"net/netip"
+)
+
+var (
+	// ErrInvalidAddress means the address can not be parsed.
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrNotFound means no store holds a non-empty meta for the address.
+	ErrNotFound = errors.New("address not found")
+	// ErrNoStoreForFamily means no store is loaded for the address family.
+	ErrNoStoreForFamily = errors.New("no store for address family")
+)
+
+// Lookup meta by address with a typed error.
+func Lookup(addr string) (Result, error) {
+	return defaultClient.Lookup(addr)
+}
+
+// LookupAddr meta by parsed address with a typed error.
+func LookupAddr(addr netip.Addr) (Result, error) {
+	return defaultClient.LookupAddr(addr)
+}
+
+// Lookup 查询ip信息, 无法解析返回ErrInvalidAddress, 未命中返回ErrNotFound或ErrNoStoreForFamily
+func (c *Client) Lookup(addr string) (Result, error) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w %q", ErrInvalidAddress, addr)
+	}
+	return c.LookupAddr(ip)
+}
+
+// LookupAddr 查询已解析的ip信息, 错误同Lookup
+func (c *Client) LookupAddr(addr netip.Addr) (Result, error) {
+	if !addr.IsValid() {
+		return Result{}, ErrInvalidAddress
+	}
+	result := c.SearchResult(addr)
+	switch {
+	case result.Overlay, result.Scope != ScopeUnclassified && result.Scope != ScopeGlobal:
+		return result, nil
+	case !result.Meta.IsEmpty():
+		return result, nil
+	case result.Store == nil && !c.hasStoreFor(result.Addr):
+		return result, ErrNoStoreForFamily
+	default:
+		return result, ErrNotFound
+	}
+}
+
+// hasStoreFor 返回是否加载了地址所属地址族的ip信息库
+func (c *Client) hasStoreFor(addr netip.Addr) bool {
+	set := c.stores.Load()
+	if set == nil {
+		return false
+	}
+	if addr.Is4() {
+		return len(set.byMode[provider.DataModeIPv4]) > 0
+	}
+	return len(set.byMode[provider.DataModeIPv6]) > 0
+}
+
+// Distance returns the great-circle distance in kilometers between two lookups,
+// false if either of them has no location.
+func Distance(a, b Result) (float64, bool) {
+	return a.Meta.DistanceTo(b.Meta)
+}
@@ -0,0 +1,204 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// maxDecodeDepth limits the nesting of the maps and arrays.
+const maxDecodeDepth = 64
+
+// decoder decodes the values of a data section, the pointers are relative to the section start.
+type decoder struct {
+	data []byte
+}
+
+// bytes returns the n bytes at the offset.
+func (d *decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset+n < offset || offset+n > uint(len(d.data)) {
+		return nil, fmt.Errorf("unexpected end of data at offset %d", offset)
+	}
+	return d.data[offset : offset+n], nil
+}
+
+// control decodes the control byte at the offset and returns the type, the size and the payload offset.
+func (d *decoder) control(offset uint) (int, uint, uint, error) {
+	b, err := d.bytes(offset, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	offset++
+	t := int(b[0] >> 5)
+	if t == typeExtended {
+		ext, err := d.bytes(offset, 1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		offset++
+		t = 7 + int(ext[0])
+		if t <= typeMap {
+			return 0, 0, 0, fmt.Errorf("invalid extended type %d", t)
+		}
+	}
+	if t == typePointer {
+		return t, uint(b[0] & 0x1F), offset, nil
+	}
+	size := uint(b[0] & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		extra, err := d.bytes(offset, n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + uint(extra[0])
+		case 2:
+			size = 285 + (uint(extra[0])<<8 | uint(extra[1]))
+		default:
+			size = 65821 + (uint(extra[0])<<16 | uint(extra[1])<<8 | uint(extra[2]))
+		}
+	}
+	return t, size, offset, nil
+}
+
+// pointer decodes the pointer of the control size bits at the offset.
+func (d *decoder) pointer(size, offset uint) (uint, uint, error) {
+	n := (size>>3)&0x3 + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	var p uint
+	if n < 4 {
+		p = size & 0x7
+	}
+	for _, v := range b {
+		p = p<<8 | uint(v)
+	}
+	switch n {
+	case 2:
+		p += 2048
+	case 3:
+		p += 526336
+	}
+	return p, offset + n, nil
+}
+
+// uint decodes the big endian unsigned int of the size at the offset.
+func (d *decoder) uint(size, offset, max uint) (uint64, error) {
+	if size > max {
+		return 0, fmt.Errorf("invalid int size %d at offset %d", size, offset)
+	}
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// decode decodes the value at the offset and returns the offset following it,
+// strings are string, unsigned ints are uint64, int32 is int64, uint128 is *big.Int.
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("data nested too deep at offset %d", offset)
+	}
+	t, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch t {
+	case typePointer:
+		p, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// a pointer never points to another pointer
+		if b, err := d.bytes(p, 1); err != nil {
+			return nil, 0, err
+		} else if int(b[0]>>5) == typePointer {
+			return nil, 0, fmt.Errorf("pointer to pointer at offset %d", p)
+		}
+		v, _, err := d.decode(p, depth+1)
+		return v, next, err
+	case typeString:
+		b, err := d.bytes(offset, size)
+		return string(b), offset + size, err
+	case typeBytes:
+		b, err := d.bytes(offset, size)
+		return append([]byte(nil), b...), offset + size, err
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d at offset %d", size, offset)
+		}
+		b, err := d.bytes(offset, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset + size, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d at offset %d", size, offset)
+		}
+		b, err := d.bytes(offset, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset + size, nil
+	case typeUint16, typeUint32, typeUint64:
+		max := map[int]uint{typeUint16: 2, typeUint32: 4, typeUint64: 8}[t]
+		v, err := d.uint(size, offset, max)
+		return v, offset + size, err
+	case typeInt32:
+		v, err := d.uint(size, offset, 4)
+		return int64(int32(uint32(v))), offset + size, err
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("invalid int size %d at offset %d", size, offset)
+		}
+		b, err := d.bytes(offset, size)
+		return new(big.Int).SetBytes(b), offset + size, err
+	case typeBoolean:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid boolean %d at offset %d", size, offset)
+		}
+		return size == 1, offset, nil
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key is not a string at offset %d", offset)
+			}
+			m[name], offset, err = d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var v interface{}
+			v, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d at offset %d", t, offset)
+	}
+}
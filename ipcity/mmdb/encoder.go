@@ -0,0 +1,156 @@
+package mmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// minPointerString is the shortest string worth a pointer to its first occurrence.
+const minPointerString = 4
+
+// encoder encodes the values of a data section and shares the repeated strings by pointers.
+type encoder struct {
+	buf     bytes.Buffer
+	strings map[string]uint32
+}
+
+func newEncoder() *encoder {
+	return &encoder{strings: make(map[string]uint32)}
+}
+
+// offset returns the offset the next value is written at.
+func (e *encoder) offset() uint32 {
+	return uint32(e.buf.Len())
+}
+
+func (e *encoder) writeControl(t int, size int) {
+	var b []byte
+	if t > typeMap {
+		b = []byte{typeExtended << 5, byte(t - 7)}
+	} else {
+		b = []byte{byte(t) << 5}
+	}
+	switch {
+	case size < 29:
+		b[0] |= byte(size)
+	case size < 285:
+		b[0] |= 29
+		b = append(b, byte(size-29))
+	case size < 65821:
+		b[0] |= 30
+		b = append(b, byte((size-285)>>8), byte(size-285))
+	default:
+		b[0] |= 31
+		v := size - 65821
+		b = append(b, byte(v>>16), byte(v>>8), byte(v))
+	}
+	e.buf.Write(b)
+}
+
+func (e *encoder) writePointer(p uint32) {
+	switch {
+	case p < 2048:
+		e.buf.Write([]byte{typePointer<<5 | byte(p>>8), byte(p)})
+	case p < 526336:
+		v := p - 2048
+		e.buf.Write([]byte{typePointer<<5 | 0x08 | byte(v>>16), byte(v >> 8), byte(v)})
+	case p < 134744064:
+		v := p - 526336
+		e.buf.Write([]byte{typePointer<<5 | 0x10 | byte(v>>24), byte(v >> 16), byte(v >> 8), byte(v)})
+	default:
+		e.buf.Write([]byte{typePointer<<5 | 0x18, byte(p >> 24), byte(p >> 16), byte(p >> 8), byte(p)})
+	}
+}
+
+func (e *encoder) writeString(s string) {
+	if len(s) >= minPointerString {
+		if p, ok := e.strings[s]; ok {
+			e.writePointer(p)
+			return
+		}
+		e.strings[s] = e.offset()
+	}
+	e.writeControl(typeString, len(s))
+	e.buf.WriteString(s)
+}
+
+// writeUint writes the unsigned int of the type in the fewest bytes.
+func (e *encoder) writeUint(t int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	n := 0
+	for n < 8 && b[n] == 0 {
+		n++
+	}
+	e.writeControl(t, 8-n)
+	e.buf.Write(b[n:])
+}
+
+// writeInt writes the int as uint32 or uint64 if not negative, as int32 if negative,
+// or as double below the int32 range since the format has no wider signed int.
+func (e *encoder) writeInt(v int) {
+	switch {
+	case v >= 0 && v <= math.MaxUint32:
+		e.writeUint(typeUint32, uint64(v))
+	case v >= 0:
+		e.writeUint(typeUint64, uint64(v))
+	case v >= math.MinInt32:
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(int32(v)))
+		e.writeControl(typeInt32, 4)
+		e.buf.Write(b[:])
+	default:
+		e.writeDouble(float64(v))
+	}
+}
+
+func (e *encoder) writeDouble(v float64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+	e.writeControl(typeDouble, 8)
+	e.buf.Write(b[:])
+}
+
+// write writes the value, the maps are written in the key order given by mapKeys.
+func (e *encoder) write(v interface{}) {
+	switch v := v.(type) {
+	case string:
+		e.writeString(v)
+	case int:
+		e.writeInt(v)
+	case float64:
+		e.writeDouble(v)
+	case uint16:
+		e.writeUint(typeUint16, uint64(v))
+	case uint32:
+		e.writeUint(typeUint32, uint64(v))
+	case uint64:
+		e.writeUint(typeUint64, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		e.writeControl(typeBoolean, size)
+	case []string:
+		e.writeControl(typeArray, len(v))
+		for _, item := range v {
+			e.writeString(item)
+		}
+	case orderedMap:
+		e.writeControl(typeMap, len(v))
+		for _, item := range v {
+			e.writeString(item.key)
+			e.write(item.value)
+		}
+	}
+}
+
+// orderedMap is a map written in the order of its items.
+type orderedMap []mapItem
+
+type mapItem struct {
+	key   string
+	value interface{}
+}
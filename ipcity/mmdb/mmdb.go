@@ -0,0 +1,117 @@
+// Package mmdb converts between ipcity stores and MaxMind DB files.
+//
+// The writer builds a binary search tree of record size 24, 28 or 32 bits, whichever fits.
+// A database of IPv4 stores only has ip_version 4. Otherwise it has ip_version 6, the IPv4
+// networks live in ::/96 and ::ffff:0:0/96 is an alias of them.
+//
+// Each network points to a map record holding the non-zero meta fields keyed by the column
+// name, e.g. {"country": "中国", "city": "广州", "countryCode": 86}. Strings are utf8_string,
+// ints are uint32, uint64 above the uint32 range, int32 if negative or double below the int32
+// range, floats are double. Empty metas are not written, so their networks are not found.
+// Records of equal metas and repeated strings are shared through pointers.
+//
+// The reader flattens the nested maps and arrays of the records into dotted paths, such as
+// "country.names.en" or "subdivisions.0.iso_code", and maps them to meta columns.
+package mmdb
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// metadataMarker starts the metadata section at the end of the file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the count of zero bytes between the search tree and the data section.
+const dataSectionSeparator = 16
+
+// data types of the data section
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBoolean  = 14
+	typeFloat    = 15
+)
+
+// Metadata defines the metadata of a database.
+type Metadata struct {
+	NodeCount                uint
+	RecordSize               uint
+	IPVersion                uint
+	DatabaseType             string
+	Languages                []string
+	BinaryFormatMajorVersion uint
+	BinaryFormatMinorVersion uint
+	BuildEpoch               time.Time
+	Description              map[string]string
+}
+
+// ReadMetadata reads the metadata of the database.
+func ReadMetadata(data []byte) (Metadata, error) {
+	i := bytes.LastIndex(data, metadataMarker)
+	if i < 0 {
+		return Metadata{}, fmt.Errorf("metadata marker not found")
+	}
+	section := data[i+len(metadataMarker):]
+	v, _, err := (&decoder{data: section}).decode(0, 0)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("decode metadata error, %s", err)
+	}
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		return Metadata{}, fmt.Errorf("metadata is not a map")
+	}
+	toUint := func(name string) uint {
+		switch n := fields[name].(type) {
+		case uint64:
+			return uint(n)
+		case int64:
+			return uint(n)
+		}
+		return 0
+	}
+	metadata := Metadata{
+		NodeCount:                toUint("node_count"),
+		RecordSize:               toUint("record_size"),
+		IPVersion:                toUint("ip_version"),
+		BinaryFormatMajorVersion: toUint("binary_format_major_version"),
+		BinaryFormatMinorVersion: toUint("binary_format_minor_version"),
+		BuildEpoch:               time.Unix(int64(toUint("build_epoch")), 0),
+		Description:              make(map[string]string),
+	}
+	metadata.DatabaseType, _ = fields["database_type"].(string)
+	if languages, ok := fields["languages"].([]interface{}); ok {
+		for _, language := range languages {
+			if s, ok := language.(string); ok {
+				metadata.Languages = append(metadata.Languages, s)
+			}
+		}
+	}
+	if description, ok := fields["description"].(map[string]interface{}); ok {
+		for lang, text := range description {
+			if s, ok := text.(string); ok {
+				metadata.Description[lang] = s
+			}
+		}
+	}
+	switch {
+	case metadata.BinaryFormatMajorVersion != 2:
+		return metadata, fmt.Errorf("unsupported binary format version %d", metadata.BinaryFormatMajorVersion)
+	case metadata.RecordSize != 24 && metadata.RecordSize != 28 && metadata.RecordSize != 32:
+		return metadata, fmt.Errorf("unsupported record size %d", metadata.RecordSize)
+	case metadata.IPVersion != 4 && metadata.IPVersion != 6:
+		return metadata, fmt.Errorf("unsupported ip version %d", metadata.IPVersion)
+	}
+	return metadata, nil
+}
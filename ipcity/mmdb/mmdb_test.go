@@ -0,0 +1,189 @@
+package mmdb
+
+import (
+	"bytes"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// newTestStore returns a store of the metas starting at the ip indexes.
+func newTestStore(mode provider.DataMode, ipIndexes []uint64, metas []*provider.Meta) *provider.Store {
+	entityList := make([]*provider.Entity, 0, len(ipIndexes))
+	for i, ipIndex := range ipIndexes {
+		entityList = append(entityList, provider.NewEntity(ipIndex, uint32(i)))
+	}
+	return provider.NewStore().
+		WithHeader(provider.NewHeader(provider.DataVersionLatest, mode)).
+		WithMetaTable(metas).
+		WithEntityList(entityList)
+}
+
+var (
+	testSchema = provider.DefaultSchema.WithColumns(
+		provider.Column{Name: provider.ColumnLatitude, Type: provider.ColumnTypeFloat},
+		provider.Column{Name: provider.ColumnTimeZone, Type: provider.ColumnTypeString},
+	)
+	testIPv4Store = newTestStore(provider.DataModeIPv4,
+		[]uint64{0x00000000, 0x01000000, 0x01000100, 0x02000000, 0x03000000},
+		[]*provider.Meta{
+			provider.NewMeta().WithSchema(testSchema),
+			provider.NewMeta().WithSchema(testSchema).WithCountry("中国").WithCity("广州").WithCountryCode(86).
+				WithField(provider.ColumnLatitude, 23.13).WithField(provider.ColumnTimeZone, "Asia/Shanghai"),
+			provider.NewMeta().WithSchema(testSchema).WithCountry("中国").WithCity("深圳").WithAreaCode(-1),
+			provider.NewMeta().WithSchema(testSchema).WithCountry("美国"),
+			provider.NewMeta().WithSchema(testSchema),
+		})
+	testIPv6Store = newTestStore(provider.DataModeIPv6,
+		[]uint64{0x0000000000000000, 0x2400000000000000, 0x2400000000000001, 0x2500000000000000},
+		[]*provider.Meta{
+			provider.NewMeta(),
+			provider.NewMeta().WithCountry("中国").WithCity("北京"),
+			provider.NewMeta().WithCountry("中国").WithCity("上海"),
+			provider.NewMeta(),
+		})
+)
+
+func TestWriteRead(t *testing.T) {
+	for _, c := range []struct {
+		stores    []*provider.Store
+		ipVersion uint
+		addrs     map[string]string
+	}{
+		{[]*provider.Store{testIPv4Store}, 4, map[string]string{
+			"0.0.0.1":   "",
+			"1.0.0.255": "广州",
+			"1.0.1.0":   "深圳",
+			"1.255.0.0": "深圳",
+			"2.1.2.3":   "",
+			"3.0.0.0":   "",
+		}},
+		{[]*provider.Store{testIPv4Store, testIPv6Store}, 6, map[string]string{
+			"1.0.0.255":             "广州",
+			"2400::1":               "北京",
+			"2400:0:0:1::":          "上海",
+			"2400:0:0:1:ffff::":     "上海",
+			"2400:0:0:2::":          "上海",
+			"2500::":                "",
+			"::1":                   "",
+			"::ffff:1.0.1.0":        "深圳",
+			"ffff:ffff:ffff:ffff::": "",
+		}},
+	} {
+		buffer := &bytes.Buffer{}
+		epoch := time.Unix(1700000000, 0)
+		if err := Write(buffer, c.stores, WriteOptions{BuildEpoch: epoch, Languages: []string{"zh-CN"}}); err != nil {
+			t.Fatal(err)
+		}
+		metadata, err := ReadMetadata(buffer.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata.IPVersion != c.ipVersion || metadata.RecordSize != 24 || metadata.DatabaseType != "IPCity" ||
+			!metadata.BuildEpoch.Equal(epoch) || len(metadata.Languages) != 1 {
+			t.Errorf("unexpected metadata %+v", metadata)
+		}
+
+		ipv4, ipv6, err := Read(buffer.Bytes(), ReadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (ipv6 != nil) != (c.ipVersion == 6) {
+			t.Errorf("unexpected IPv6 store %v of ip version %d", ipv6, c.ipVersion)
+		}
+		for addr, city := range c.addrs {
+			ip := netip.MustParseAddr(addr)
+			store := ipv4
+			if ip.Is6() && !ip.Is4In6() {
+				store = ipv6
+			}
+			if meta := store.SearchAddr(ip.Unmap()); meta.City() != city {
+				t.Errorf("search %s got city %q, want %q", addr, meta.City(), city)
+			}
+		}
+		meta := ipv4.SearchAddr(netip.MustParseAddr("1.0.0.0"))
+		if meta.CountryCode() != 86 || meta.Get(provider.ColumnLatitude) != 23.13 ||
+			meta.Get(provider.ColumnTimeZone) != "Asia/Shanghai" {
+			t.Errorf("unexpected meta %v", meta.Fields())
+		}
+		if meta = ipv4.SearchAddr(netip.MustParseAddr("1.0.1.0")); meta.AreaCode() != -1 {
+			t.Errorf("unexpected area code %d", meta.AreaCode())
+		}
+		if _, err = ipv4.MarshalBinary(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReadFields(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := Write(buffer, []*provider.Store{testIPv4Store}, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ipv4, _, err := Read(buffer.Bytes(), ReadOptions{Fields: map[string]string{"city": "name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := ipv4.SearchAddr(netip.MustParseAddr("1.0.0.0"))
+	if meta.Get("name") != "广州" || meta.City() != "" || meta.Country() != "" {
+		t.Errorf("unexpected meta %v", meta.Fields())
+	}
+}
+
+func TestWriteReadLargeInt(t *testing.T) {
+	schema := provider.DefaultSchema.WithColumns(provider.Column{Name: "population", Type: provider.ColumnTypeInt})
+	store := newTestStore(provider.DataModeIPv4,
+		[]uint64{0x00000000, 0x01000000, 0x02000000, 0x03000000},
+		[]*provider.Meta{
+			provider.NewMeta().WithSchema(schema),
+			provider.NewMeta().WithSchema(schema).WithCity("广州").WithField("population", 5000000000),
+			provider.NewMeta().WithSchema(schema).WithCity("深圳").WithField("population", -5),
+			provider.NewMeta().WithSchema(schema),
+		})
+	buffer := &bytes.Buffer{}
+	if err := Write(buffer, []*provider.Store{store}, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ipv4, _, err := Read(buffer.Bytes(), ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]int{"1.0.0.0": 5000000000, "2.0.0.0": -5} {
+		if meta := ipv4.SearchAddr(netip.MustParseAddr(addr)); meta.Get("population") != want {
+			t.Errorf("got population %v of %s, want %d", meta.Get("population"), addr, want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	// a map of a string and a pointer to it
+	v, _, err := (&decoder{data: []byte{0xE2,
+		0x41, 'a', 0x44, 't', 'e', 's', 't',
+		0x41, 'b', 0x20, 0x03}}).decode(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := v.(map[string]interface{}); m["a"] != "test" || m["b"] != "test" {
+		t.Errorf("unexpected map %v", m)
+	}
+	for _, c := range []struct {
+		data []byte
+		want interface{}
+	}{
+		{[]byte{0xA2, 0x01, 0x00}, uint64(256)},
+		{[]byte{0x04, 0x01, 0xFF, 0xFF, 0xFF, 0xFE}, int64(-2)},
+		{[]byte{0x00, 0x07}, false},
+		{[]byte{0x01, 0x07}, true},
+		{append([]byte{0x5D, 0x01}, bytes.Repeat([]byte{'x'}, 30)...), string(bytes.Repeat([]byte{'x'}, 30))},
+	} {
+		if v, _, err := (&decoder{data: c.data}).decode(0, 0); err != nil || v != c.want {
+			t.Errorf("decode %x got %v %v, want %v", c.data, v, err, c.want)
+		}
+	}
+	for _, data := range [][]byte{{0x44, 'a'}, {0x20, 0x00}, {0x00, 0x00}, {0xE1, 0xA1, 0x01}} {
+		if _, _, err := (&decoder{data: data}).decode(0, 0); err == nil {
+			t.Errorf("decode %x got no error", data)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package mmdb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"math"
+	"math/big"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// ReadOptions are the options of reading a database into stores.
+type ReadOptions struct {
+	// Fields maps the flattened record paths to the meta columns, the paths not in it
+	// are dropped, nil keeps every path as the column of the same name
+	Fields map[string]string
+}
+
+// GeoIP2Fields returns the fields of the GeoIP2 and GeoLite2 City, Country and ASN records
+// with the names in the language, such as "en" or "zh-CN".
+func GeoIP2Fields(lang string) map[string]string {
+	return map[string]string{
+		"country.names." + lang:          provider.ColumnCountry,
+		"subdivisions.0.names." + lang:   provider.ColumnProvince,
+		"city.names." + lang:             provider.ColumnCity,
+		"location.latitude":              provider.ColumnLatitude,
+		"location.longitude":             provider.ColumnLongitude,
+		"location.accuracy_radius":       provider.ColumnAccuracyRadius,
+		"location.time_zone":             provider.ColumnTimeZone,
+		"postal.code":                    provider.ColumnPostalCode,
+		"autonomous_system_number":       provider.ColumnASN,
+		"autonomous_system_organization": provider.ColumnASOrganization,
+	}
+}
+
+// network is a network of the search tree and the offset of its data record.
+type network struct {
+	prefix netip.Prefix
+	offset uint
+}
+
+// ReadFile reads the database file, see Read.
+func ReadFile(filename string, options ReadOptions) (ipv4, ipv6 *provider.Store, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Read(data, options)
+}
+
+// Read reads the database into an IPv4 and an IPv6 store, a store is nil if the database has
+// no network of its family. The addresses out of the networks get the empty meta. The IPv6
+// index of a store is 64 bits, so the networks narrower than /64 are widened to their /64
+// and the first of them wins.
+func Read(data []byte, options ReadOptions) (ipv4, ipv6 *provider.Store, err error) {
+	metadata, err := ReadMetadata(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	treeSize := metadata.NodeCount * metadata.RecordSize / 4
+	end := uint(bytes.LastIndex(data, metadataMarker))
+	if treeSize+dataSectionSeparator > end {
+		return nil, nil, fmt.Errorf("search tree of %d nodes exceeds the database", metadata.NodeCount)
+	}
+	r := &reader{
+		metadata: metadata,
+		tree:     data[:treeSize],
+		data:     &decoder{data: data[treeSize+dataSectionSeparator : end]},
+		visited:  make(map[uint]bool),
+	}
+	bitLen := 32
+	if metadata.IPVersion == 6 {
+		bitLen = 128
+	}
+	var key [16]byte
+	if err = r.walk(0, key, 0, bitLen); err != nil {
+		return nil, nil, err
+	}
+	return r.stores(options)
+}
+
+type reader struct {
+	metadata Metadata
+	tree     []byte
+	data     *decoder
+	visited  map[uint]bool
+	ipv4     []network
+	ipv6     []network
+}
+
+// record returns the left or right record of the node.
+func (r *reader) record(node uint, right int) uint {
+	size := r.metadata.RecordSize
+	b := r.tree[node*size/4 : (node+1)*size/4]
+	switch size {
+	case 24:
+		b = b[right*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if right == 0 {
+			return uint(b[3]>>4)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		b = b[right*4:]
+		return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+	}
+}
+
+// walk collects the networks under the node of the key at the depth, the aliased nodes are walked once.
+func (r *reader) walk(node uint, key [16]byte, depth, bitLen int) error {
+	r.visited[node] = true
+	for side := 0; side < 2; side++ {
+		if side == 1 {
+			key[depth/8] |= 0x80 >> (depth % 8)
+		}
+		value := r.record(node, side)
+		switch {
+		case value < r.metadata.NodeCount:
+			if depth+1 >= bitLen {
+				return fmt.Errorf("search tree deeper than %d bits", bitLen)
+			}
+			if !r.visited[value] {
+				if err := r.walk(value, key, depth+1, bitLen); err != nil {
+					return err
+				}
+			}
+		case value > r.metadata.NodeCount:
+			if value < r.metadata.NodeCount+dataSectionSeparator ||
+				value-r.metadata.NodeCount-dataSectionSeparator >= uint(len(r.data.data)) {
+				return fmt.Errorf("invalid data record %d", value)
+			}
+			r.add(key, depth+1, bitLen, value-r.metadata.NodeCount-dataSectionSeparator)
+		}
+	}
+	return nil
+}
+
+// add adds the network of the leading bits of the key, the IPv4 networks of an IPv6 tree are in ::/96.
+func (r *reader) add(key [16]byte, bits, bitLen int, offset uint) {
+	if bitLen == 32 {
+		r.ipv4 = append(r.ipv4, network{netip.PrefixFrom(netip.AddrFrom4([4]byte(key[:4])), bits), offset})
+		return
+	}
+	if key == [16]byte{} && bits <= 96 {
+		r.ipv4 = append(r.ipv4, network{netip.PrefixFrom(netip.IPv4Unspecified(), 0), offset})
+	} else if bits > 96 && [12]byte(key[:12]) == [12]byte{} {
+		r.ipv4 = append(r.ipv4, network{netip.PrefixFrom(netip.AddrFrom4([4]byte(key[12:])), bits-96), offset})
+		return
+	}
+	r.ipv6 = append(r.ipv6, network{netip.PrefixFrom(netip.AddrFrom16(key), bits), offset})
+}
+
+// stores decodes the records of the networks into the metas of the stores.
+func (r *reader) stores(options ReadOptions) (ipv4, ipv6 *provider.Store, err error) {
+	records := make(map[uint]map[string]interface{})
+	for _, networks := range [][]network{r.ipv4, r.ipv6} {
+		for _, n := range networks {
+			if _, ok := records[n.offset]; ok {
+				continue
+			}
+			v, _, err := r.data.decode(n.offset, 0)
+			if err != nil {
+				return nil, nil, fmt.Errorf("decode data record %d error, %s", n.offset, err)
+			}
+			record := make(map[string]interface{})
+			flatten("", v, record)
+			if options.Fields != nil {
+				mapped := make(map[string]interface{}, len(record))
+				for path, value := range record {
+					if name, ok := options.Fields[path]; ok {
+						mapped[name] = value
+					}
+				}
+				record = mapped
+			}
+			records[n.offset] = record
+		}
+	}
+	schema := recordSchema(records)
+
+	metas := make(map[uint]*provider.Meta, len(records))
+	for offset, record := range records {
+		meta := provider.NewMeta()
+		for name, value := range record {
+			if v := coerce(value, schema.Column(schema.Index(name)).Type); v != nil {
+				meta.WithField(name, v)
+			}
+		}
+		metas[offset] = meta
+	}
+
+	build := func(mode provider.DataMode, networks []network) (*provider.Store, error) {
+		if len(networks) == 0 {
+			return nil, nil
+		}
+		builder := provider.NewStoreBuilder(mode).
+			WithSchema(schema).
+			WithSourceUpdatedTime(r.metadata.BuildEpoch.Unix())
+		for _, n := range networks {
+			if err := builder.Add(provider.PrefixRange(n.prefix), metas[n.offset]); err != nil {
+				return nil, err
+			}
+		}
+		return builder.Build(), nil
+	}
+	if ipv4, err = build(provider.DataModeIPv4, r.ipv4); err != nil {
+		return nil, nil, err
+	}
+	if ipv6, err = build(provider.DataModeIPv6, r.ipv6); err != nil {
+		return nil, nil, err
+	}
+	return ipv4, ipv6, nil
+}
+
+// flatten sets the scalar values of the nested maps and arrays by their dotted paths.
+func flatten(path string, v interface{}, record map[string]interface{}) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			flatten(join(key), value, record)
+		}
+	case []interface{}:
+		for i, value := range v {
+			flatten(join(strconv.Itoa(i)), value, record)
+		}
+	case string, float64:
+		record[path] = v
+	case uint64:
+		if v <= math.MaxInt {
+			record[path] = int(v)
+		} else {
+			record[path] = strconv.FormatUint(v, 10)
+		}
+	case int64:
+		record[path] = int(v)
+	case *big.Int:
+		record[path] = v.String()
+	case bool:
+		record[path] = strconv.FormatBool(v)
+	case []byte:
+		record[path] = hex.EncodeToString(v)
+	}
+}
+
+// recordSchema returns the default schema followed by the other columns of the records by name,
+// a column of mixed ints and floats is float, of other mixed types is string.
+func recordSchema(records map[uint]map[string]interface{}) *provider.Schema {
+	types := make(map[string]provider.ColumnType)
+	for _, record := range records {
+		for name, value := range record {
+			if provider.DefaultSchema.Index(name) >= 0 {
+				continue
+			}
+			t := provider.ColumnTypeString
+			switch value.(type) {
+			case int:
+				t = provider.ColumnTypeInt
+			case float64:
+				t = provider.ColumnTypeFloat
+			}
+			switch former, ok := types[name]; {
+			case !ok || former == t:
+				types[name] = t
+			case former != provider.ColumnTypeString && t != provider.ColumnTypeString:
+				types[name] = provider.ColumnTypeFloat
+			default:
+				types[name] = provider.ColumnTypeString
+			}
+		}
+	}
+	columns := make([]provider.Column, 0, len(types))
+	for _, name := range sortedKeys(types) {
+		columns = append(columns, provider.Column{Name: name, Type: types[name]})
+	}
+	return provider.DefaultSchema.WithColumns(columns...)
+}
+
+// coerce converts the value to the column type, nil if it does not fit.
+func coerce(v interface{}, t provider.ColumnType) interface{} {
+	switch t {
+	case provider.ColumnTypeInt:
+		switch v := v.(type) {
+		case int:
+			return v
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt {
+				return int(v)
+			}
+		}
+		return nil
+	case provider.ColumnTypeFloat:
+		switch v := v.(type) {
+		case int:
+			return float64(v)
+		case float64:
+			return v
+		}
+		return nil
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sortedKeys returns the keys of the map in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
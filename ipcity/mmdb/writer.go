@@ -0,0 +1,247 @@
+package mmdb
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"math"
+	"net/netip"
+	"time"
+)
+
+// WriteOptions are the metadata options of the written database.
+type WriteOptions struct {
+	// DatabaseType is the database_type, "IPCity" if empty
+	DatabaseType string
+	// Languages are the languages of the localized names in the records
+	Languages []string
+	// Description maps the languages to the descriptions of the database
+	Description map[string]string
+	// BuildEpoch is the build time, now if zero
+	BuildEpoch time.Time
+}
+
+// record kinds of the search tree
+const (
+	recordEmpty = iota
+	recordNode
+	recordData
+)
+
+// treeRecord points to a node, to a data record or to nothing.
+type treeRecord struct {
+	kind  byte
+	value uint32
+}
+
+// tree is the in-memory search tree, the root is the first node.
+type tree struct {
+	nodes [][2]treeRecord
+}
+
+func (t *tree) newNode(left, right treeRecord) uint32 {
+	t.nodes = append(t.nodes, [2]treeRecord{left, right})
+	return uint32(len(t.nodes) - 1)
+}
+
+func bit(key []byte, i int) int {
+	return int(key[i/8]>>(7-i%8)) & 1
+}
+
+// insert points the network of the leading bits of the key to the record, replacing the former records.
+func (t *tree) insert(key []byte, bits int, r treeRecord) {
+	if bits == 0 {
+		t.nodes[0] = [2]treeRecord{r, r}
+		return
+	}
+	node := uint32(0)
+	for i := 0; i < bits-1; i++ {
+		b := bit(key, i)
+		child := t.nodes[node][b]
+		if child.kind != recordNode {
+			// a former network is split to hold the narrower one
+			n := t.newNode(child, child)
+			t.nodes[node][b] = treeRecord{kind: recordNode, value: n}
+			child = t.nodes[node][b]
+		}
+		node = child.value
+	}
+	t.nodes[node][bit(key, bits-1)] = r
+}
+
+// get returns the record of the network of the leading bits of the key.
+func (t *tree) get(key []byte, bits int) treeRecord {
+	r := treeRecord{kind: recordNode}
+	for i := 0; i < bits && r.kind == recordNode; i++ {
+		r = t.nodes[r.value][bit(key, i)]
+	}
+	return r
+}
+
+// ipv4Mapped is the ::ffff:0:0/96 network aliased to the IPv4 networks.
+var ipv4Mapped = netip.MustParseAddr("::ffff:0:0").As16()
+
+// treeKey returns the tree key and bits of the prefix in a tree of the ip version.
+func treeKey(prefix netip.Prefix, ipVersion int) ([]byte, int) {
+	if prefix.Addr().Is4() {
+		b := prefix.Addr().As4()
+		if ipVersion == 4 {
+			return b[:], prefix.Bits()
+		}
+		var key [16]byte
+		copy(key[12:], b[:])
+		return key[:], prefix.Bits() + 96
+	}
+	b := prefix.Addr().As16()
+	return b[:], prefix.Bits()
+}
+
+// Write writes the stores as a database, the earlier store wins on overlapping networks.
+func Write(w io.Writer, stores []*provider.Store, options WriteOptions) error {
+	ipVersion := 4
+	for _, store := range stores {
+		switch store.Header().AddrBitLen() {
+		case 32:
+		case 128:
+			ipVersion = 6
+		default:
+			return fmt.Errorf("unsupported store mode %s", store.Header().ModeName())
+		}
+	}
+
+	data := newEncoder()
+	records := make(map[string]uint32)
+	t := &tree{}
+	t.newNode(treeRecord{}, treeRecord{})
+	for i := len(stores) - 1; i >= 0; i-- {
+		store := stores[i]
+		offsets := make([]treeRecord, store.MetaRowCount())
+		for j, meta := range store.MetaTable() {
+			m := metaRecord(meta)
+			if len(m) == 0 {
+				continue
+			}
+			key := fmt.Sprintf("%#v", m)
+			offset, ok := records[key]
+			if !ok {
+				offset = data.offset()
+				records[key] = offset
+				data.write(m)
+			}
+			offsets[j] = treeRecord{kind: recordData, value: offset}
+		}
+		for j, entity := range store.EntityList() {
+			var r treeRecord
+			if index := int(entity.MetaRowIndex()); index < len(offsets) {
+				r = offsets[index]
+			}
+			if r.kind == recordEmpty {
+				continue
+			}
+			for _, prefix := range store.EntityRange(j).Prefixes() {
+				key, bits := treeKey(prefix, ipVersion)
+				t.insert(key, bits, r)
+			}
+		}
+	}
+	if ipVersion == 6 {
+		var zero [16]byte
+		t.insert(ipv4Mapped[:], 96, t.get(zero[:], 96))
+	}
+
+	nodeCount := uint64(len(t.nodes))
+	recordSize := 0
+	switch max := nodeCount + dataSectionSeparator + uint64(data.offset()); {
+	case max < 1<<24:
+		recordSize = 24
+	case max < 1<<28:
+		recordSize = 28
+	case max <= math.MaxUint32:
+		recordSize = 32
+	default:
+		return fmt.Errorf("database of %d nodes and %d data bytes is too large", nodeCount, data.offset())
+	}
+	value := func(r treeRecord) uint32 {
+		switch r.kind {
+		case recordNode:
+			return r.value
+		case recordData:
+			return uint32(nodeCount) + dataSectionSeparator + r.value
+		default:
+			return uint32(nodeCount)
+		}
+	}
+
+	writer := bufio.NewWriter(w)
+	for _, node := range t.nodes {
+		l, r := value(node[0]), value(node[1])
+		switch recordSize {
+		case 24:
+			_, _ = writer.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(r >> 16), byte(r >> 8), byte(r)})
+		case 28:
+			_, _ = writer.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l),
+				byte(l>>24)<<4 | byte(r>>24)&0x0F, byte(r >> 16), byte(r >> 8), byte(r)})
+		default:
+			_, _ = writer.Write([]byte{byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l),
+				byte(r >> 24), byte(r >> 16), byte(r >> 8), byte(r)})
+		}
+	}
+	_, _ = writer.Write(make([]byte, dataSectionSeparator))
+	_, _ = writer.Write(data.buf.Bytes())
+	_, _ = writer.Write(metadataMarker)
+	metadata := newEncoder()
+	metadata.write(options.metadata(uint32(nodeCount), recordSize, ipVersion))
+	_, _ = writer.Write(metadata.buf.Bytes())
+	return writer.Flush()
+}
+
+// metadata returns the metadata map of the database.
+func (o WriteOptions) metadata(nodeCount uint32, recordSize, ipVersion int) orderedMap {
+	if o.DatabaseType == "" {
+		o.DatabaseType = "IPCity"
+	}
+	if o.BuildEpoch.IsZero() {
+		o.BuildEpoch = time.Now()
+	}
+	description := orderedMap{}
+	for _, lang := range sortedKeys(o.Description) {
+		description = append(description, mapItem{lang, o.Description[lang]})
+	}
+	return orderedMap{
+		{"node_count", nodeCount},
+		{"record_size", uint16(recordSize)},
+		{"ip_version", uint16(ipVersion)},
+		{"database_type", o.DatabaseType},
+		{"languages", append([]string{}, o.Languages...)},
+		{"binary_format_major_version", uint16(2)},
+		{"binary_format_minor_version", uint16(0)},
+		{"build_epoch", uint64(o.BuildEpoch.Unix())},
+		{"description", description},
+	}
+}
+
+// metaRecord returns the non-zero fields of the meta in field order.
+func metaRecord(meta *provider.Meta) orderedMap {
+	var m orderedMap
+	if meta.IsEmpty() {
+		return m
+	}
+	for _, field := range meta.Fields() {
+		switch v := field.Value.(type) {
+		case string:
+			if v != "" {
+				m = append(m, mapItem{field.Name, v})
+			}
+		case int:
+			if v != 0 {
+				m = append(m, mapItem{field.Name, v})
+			}
+		case float64:
+			if v != 0 {
+				m = append(m, mapItem{field.Name, v})
+			}
+		}
+	}
+	return m
+}
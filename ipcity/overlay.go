@@ -0,0 +1,210 @@
+package ipcity
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Overlay defines local override ranges searched before the stores by longest-prefix match.
+type Overlay struct {
+	table prefixTable[*Meta]
+}
+
+// NewOverlay returns a new empty overlay.
+func NewOverlay() *Overlay {
+	return &Overlay{}
+}
+
+// Add adds or replaces the meta of a prefix in the overlay.
+func (o *Overlay) Add(prefix netip.Prefix, meta *Meta) {
+	if o != nil && prefix.IsValid() {
+		o.table.add(prefix, meta)
+	}
+}
+
+// Len returns the count of prefixes in the overlay.
+func (o *Overlay) Len() int {
+	if o != nil {
+		return len(o.table.prefixes)
+	}
+	return 0
+}
+
+// Search returns the meta of the longest prefix containing the address.
+func (o *Overlay) Search(addr netip.Addr) (*Meta, netip.Prefix) {
+	if o != nil {
+		if meta, prefix, ok := o.table.search(addr); ok {
+			return meta, prefix
+		}
+	}
+	return nil, netip.Prefix{}
+}
+
+// search returns the overlay result of the address.
+func (o *Overlay) search(addr netip.Addr) (Result, bool) {
+	meta, prefix := o.Search(addr)
+	if meta == nil {
+		return Result{}, false
+	}
+	return Result{Meta: meta, Addr: addr, Range: provider.PrefixRange(prefix), Overlay: true}, true
+}
+
+// overlayColumns is the default column order of overlay files.
+var overlayColumns = []string{
+	"cidr", FieldCountry, FieldProvince, FieldCity, FieldDistrict,
+	FieldISP, FieldBackboneISP, FieldCountryCode, FieldAreaCode,
+}
+
+// overlayMeta builds the meta of an overlay row from column values.
+func overlayMeta(values map[string]string) (*Meta, error) {
+	meta := provider.NewMeta().
+		WithCountry(values[FieldCountry]).
+		WithProvince(values[FieldProvince]).
+		WithCity(values[FieldCity]).
+		WithDistrict(values[FieldDistrict]).
+		WithISP(values[FieldISP]).
+		WithBackboneISP(values[FieldBackboneISP])
+	columns := append([]provider.Column{
+		{Name: FieldCountryCode, Type: provider.ColumnTypeInt},
+		{Name: FieldAreaCode, Type: provider.ColumnTypeInt},
+	}, provider.LocationColumns...)
+	for _, column := range columns {
+		v := values[column.Name]
+		if v == "" {
+			continue
+		}
+		switch column.Type {
+		case provider.ColumnTypeInt:
+			code, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", column.Name, v)
+			}
+			meta.WithField(column.Name, code)
+		case provider.ColumnTypeFloat:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", column.Name, v)
+			}
+			meta.WithField(column.Name, f)
+		default:
+			meta.WithField(column.Name, v)
+		}
+	}
+	return meta, nil
+}
+
+// parseOverlayPrefix parses a CIDR or a single address.
+func parseOverlayPrefix(cidr string) (netip.Prefix, error) {
+	if strings.Contains(cidr, "/") {
+		return netip.ParsePrefix(cidr)
+	}
+	addr, err := netip.ParseAddr(cidr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ReadOverlayCSV reads overlay rows from CSV, the columns are cidr, country, province, city,
+// district, isp, backboneISP, countryCode and areaCode, or named by a header row starting with cidr
+// which may add the location columns.
+func ReadOverlayCSV(reader io.Reader, overlay *Overlay) error {
+	if overlay == nil {
+		return fmt.Errorf("work with nil Overlay")
+	}
+	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	columns := overlayColumns
+	for row := 0; ; row++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if row == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "cidr") {
+			columns = record
+			continue
+		}
+		values := make(map[string]string, len(columns))
+		for i, value := range record {
+			if i < len(columns) {
+				values[strings.TrimSpace(columns[i])] = strings.TrimSpace(value)
+			}
+		}
+		if err = addOverlayRow(overlay, values); err != nil {
+			return fmt.Errorf("overlay row %d error, %s", row+1, err)
+		}
+	}
+}
+
+// ReadOverlayYAML reads overlay rows from a YAML list of mappings with the CSV column names as keys.
+func ReadOverlayYAML(reader io.Reader, overlay *Overlay) error {
+	if overlay == nil {
+		return fmt.Errorf("work with nil Overlay")
+	}
+	var rows []map[string]interface{}
+	if err := yaml.NewDecoder(reader).Decode(&rows); err != nil && err != io.EOF {
+		return err
+	}
+	for i, row := range rows {
+		values := make(map[string]string, len(row))
+		for k, v := range row {
+			if v != nil {
+				values[k] = fmt.Sprint(v)
+			}
+		}
+		if err := addOverlayRow(overlay, values); err != nil {
+			return fmt.Errorf("overlay row %d error, %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+func addOverlayRow(overlay *Overlay, values map[string]string) error {
+	prefix, err := parseOverlayPrefix(values["cidr"])
+	if err != nil {
+		return err
+	}
+	meta, err := overlayMeta(values)
+	if err != nil {
+		return err
+	}
+	overlay.Add(prefix, meta)
+	return nil
+}
+
+// LoadOverlayFiles reads the overlay files in order, later rows replace earlier ones of the same prefix.
+func LoadOverlayFiles(filenames ...string) (*Overlay, error) {
+	overlay := NewOverlay()
+	for _, filename := range filenames {
+		if err := func() error {
+			file, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+			switch strings.ToLower(filepath.Ext(filename)) {
+			case ".yaml", ".yml":
+				return ReadOverlayYAML(file, overlay)
+			default:
+				return ReadOverlayCSV(file, overlay)
+			}
+		}(); err != nil {
+			return nil, fmt.Errorf("load overlay %s error, %s", filename, err)
+		}
+	}
+	return overlay, nil
+}
@@ -0,0 +1,58 @@
+package ipcity
+
+import (
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientOverlay(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "office.csv")
+	yamlFile := filepath.Join(dir, "vpn.yaml")
+	if err := os.WriteFile(csvFile, []byte("# offices\n"+
+		"cidr,country,city,isp\n"+
+		"1.2.0.0/16,中国,深圳,内网\n"+
+		"1.2.3.0/24,中国,上海,内网\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(yamlFile, []byte("- cidr: fd00::/8\n  country: 中国\n  city: 杭州\n  areaCode: 330100\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient()
+	if err := client.Load(writeTestStore(t, provider.DataModeIPv4, testIPv4Rows)); err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range []string{csvFile, yamlFile} {
+		if err := client.LoadOverlay(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for addr, city := range map[string]string{
+		"1.2.3.4":        "上海",
+		"1.2.4.4":        "深圳",
+		"::ffff:1.2.4.4": "深圳",
+		"1.3.0.1":        "广州",
+		"fd12::1":        "杭州",
+	} {
+		if result := client.SearchResult(netip.MustParseAddr(addr)); result.Meta.City() != city {
+			t.Errorf("search %s got city %q, want %q", addr, result.Meta.City(), city)
+		}
+	}
+	if result := client.SearchResult(netip.MustParseAddr("fd12::1")); !result.Overlay || result.Meta.AreaCode() != 330100 {
+		t.Errorf("unexpected overlay result %+v", result)
+	}
+
+	if err := os.WriteFile(csvFile, []byte("1.2.0.0/16,中国,,,,,,,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if meta := client.Search("1.2.3.4"); meta.City() != "" || meta.Country() != "中国" {
+		t.Errorf("unexpected meta after reload %s", meta)
+	}
+}
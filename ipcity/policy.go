@@ -0,0 +1,213 @@
+package ipcity
+
+import (
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"net/netip"
+)
+
+// Policy is the way the client resolves a search across stores.
+type Policy byte
+
+const (
+	// PolicyFirstMatch returns the first non-empty meta in load order.
+	PolicyFirstMatch = Policy(0)
+	// PolicyMostSpecific returns the non-empty meta of the narrowest matching range.
+	PolicyMostSpecific = Policy(1)
+	// PolicyFieldMerge fills the empty fields of the first match from lower-priority stores.
+	PolicyFieldMerge = Policy(2)
+)
+
+// PolicyName is a mapping for policy name.
+var PolicyName = map[Policy]string{
+	PolicyFirstMatch:   "first-match",
+	PolicyMostSpecific: "most-specific",
+	PolicyFieldMerge:   "field-merge",
+}
+
+func (p Policy) String() string {
+	return PolicyName[p]
+}
+
+// Field names reported in Result.FieldStores.
+const (
+	FieldCountry     = provider.ColumnCountry
+	FieldProvince    = provider.ColumnProvince
+	FieldCity        = provider.ColumnCity
+	FieldDistrict    = provider.ColumnDistrict
+	FieldISP         = provider.ColumnISP
+	FieldBackboneISP = provider.ColumnBackboneISP
+	FieldCountryCode = provider.ColumnCountryCode
+	FieldAreaCode    = provider.ColumnAreaCode
+)
+
+// WithPolicy 设置多个ip信息库的查询策略, 需在client开始查询前设置
+func (c *Client) WithPolicy(policy Policy) *Client {
+	if c != nil {
+		c.policy = policy
+	}
+	return c
+}
+
+// Policy 返回多个ip信息库的查询策略
+func (c *Client) Policy() Policy {
+	if c != nil {
+		return c.policy
+	}
+	return PolicyFirstMatch
+}
+
+// resolve 按查询策略在同一地址族的ip信息库中查询
+func (c *Client) resolve(stores []*Store, addr netip.Addr) Result {
+	switch c.Policy() {
+	case PolicyMostSpecific:
+		return resolveMostSpecific(stores, addr)
+	case PolicyFieldMerge:
+		return resolveFieldMerge(stores, addr)
+	default:
+		return resolveFirstMatch(stores, addr)
+	}
+}
+
+func resolveFirstMatch(stores []*Store, addr netip.Addr) Result {
+	result := Result{Addr: addr}
+	for _, store := range stores {
+		meta, r := store.SearchRange(addr)
+		result.Meta, result.Range, result.Store = meta, r, store
+		if meta != nil && !meta.IsEmpty() {
+			break
+		}
+	}
+	return result
+}
+
+func resolveMostSpecific(stores []*Store, addr netip.Addr) Result {
+	result := Result{Addr: addr}
+	for _, store := range stores {
+		meta, r := store.SearchRange(addr)
+		if meta == nil || meta.IsEmpty() {
+			continue
+		}
+		if result.Store == nil || r.Narrower(result.Range) {
+			result.Meta, result.Range, result.Store = meta, r, store
+		}
+	}
+	return result
+}
+
+// mergeField is a meta field which can be filled from a lower-priority store.
+type mergeField struct {
+	name string
+	// empty returns true if the field is not set in the meta
+	empty func(m *Meta) bool
+	// fill copies the field from src to dst
+	fill func(dst, src *Meta)
+	// parents are the coarser fields which must agree before the field is filled
+	parents []string
+}
+
+var mergeFields = []mergeField{
+	{
+		name:  FieldCountry,
+		empty: func(m *Meta) bool { return m.Country() == "" },
+		fill:  func(dst, src *Meta) { dst.WithCountry(src.Country()) },
+	},
+	{
+		name:    FieldProvince,
+		empty:   func(m *Meta) bool { return m.Province() == "" },
+		fill:    func(dst, src *Meta) { dst.WithProvince(src.Province()) },
+		parents: []string{FieldCountry},
+	},
+	{
+		name:    FieldCity,
+		empty:   func(m *Meta) bool { return m.City() == "" },
+		fill:    func(dst, src *Meta) { dst.WithCity(src.City()) },
+		parents: []string{FieldCountry, FieldProvince},
+	},
+	{
+		name:    FieldDistrict,
+		empty:   func(m *Meta) bool { return m.District() == "" },
+		fill:    func(dst, src *Meta) { dst.WithDistrict(src.District()) },
+		parents: []string{FieldCountry, FieldProvince, FieldCity},
+	},
+	{
+		name:  FieldISP,
+		empty: func(m *Meta) bool { return m.ISP() == "" },
+		fill:  func(dst, src *Meta) { dst.WithISP(src.ISP()) },
+	},
+	{
+		name:  FieldBackboneISP,
+		empty: func(m *Meta) bool { return m.BackboneISP() == "" },
+		fill:  func(dst, src *Meta) { dst.WithBackboneISP(src.BackboneISP()) },
+	},
+	{
+		name:    FieldCountryCode,
+		empty:   func(m *Meta) bool { return m.CountryCode() == 0 },
+		fill:    func(dst, src *Meta) { dst.WithCountryCode(src.CountryCode()) },
+		parents: []string{FieldCountry},
+	},
+	{
+		name:    FieldAreaCode,
+		empty:   func(m *Meta) bool { return m.AreaCode() == 0 },
+		fill:    func(dst, src *Meta) { dst.WithAreaCode(src.AreaCode()) },
+		parents: []string{FieldCountry, FieldProvince, FieldCity, FieldDistrict},
+	},
+}
+
+// mergeFieldValue returns the string value of a geographic parent field.
+func mergeFieldValue(m *Meta, name string) string {
+	switch name {
+	case FieldCountry:
+		return m.Country()
+	case FieldProvince:
+		return m.Province()
+	case FieldCity:
+		return m.City()
+	case FieldDistrict:
+		return m.District()
+	default:
+		return ""
+	}
+}
+
+// consistent returns true if the non-empty parent fields of src agree with dst.
+func (f *mergeField) consistent(dst, src *Meta) bool {
+	for _, parent := range f.parents {
+		if v := mergeFieldValue(src, parent); v != "" && v != mergeFieldValue(dst, parent) {
+			return false
+		}
+	}
+	return true
+}
+
+func resolveFieldMerge(stores []*Store, addr netip.Addr) Result {
+	result := resolveFirstMatch(stores, addr)
+	if result.Meta == nil || result.Meta.IsEmpty() {
+		return result
+	}
+
+	merged := *result.Meta
+	result.FieldStores = make(map[string]*Store, len(mergeFields))
+	for _, field := range mergeFields {
+		if !field.empty(&merged) {
+			result.FieldStores[field.name] = result.Store
+		}
+	}
+	for _, store := range stores {
+		if store == result.Store {
+			continue
+		}
+		meta := store.SearchAddr(addr)
+		if meta == nil || meta.IsEmpty() {
+			continue
+		}
+		for i := range mergeFields {
+			field := &mergeFields[i]
+			if field.empty(&merged) && !field.empty(meta) && field.consistent(&merged, meta) {
+				field.fill(&merged, meta)
+				result.FieldStores[field.name] = store
+			}
+		}
+	}
+	result.Meta = &merged
+	return result
+}
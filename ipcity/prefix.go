@@ -0,0 +1,61 @@
+package ipcity
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// prefixTable maps prefixes to values and searches them by longest-prefix match.
+type prefixTable[T any] struct {
+	prefixes map[netip.Prefix]T
+	// bits holds the prefix lengths in use for IPv4 and IPv6, longest first
+	bits [2][]int
+}
+
+func familyIndex(addr netip.Addr) int {
+	if addr.Is4() {
+		return 0
+	}
+	return 1
+}
+
+func (t *prefixTable[T]) add(prefix netip.Prefix, value T) {
+	prefix = prefix.Masked()
+	if t.prefixes == nil {
+		t.prefixes = make(map[netip.Prefix]T)
+	}
+	if _, ok := t.prefixes[prefix]; !ok {
+		family := familyIndex(prefix.Addr())
+		bits := t.bits[family]
+		if i := sort.Search(len(bits), func(i int) bool {
+			return bits[i] <= prefix.Bits()
+		}); i == len(bits) || bits[i] != prefix.Bits() {
+			bits = append(bits, 0)
+			copy(bits[i+1:], bits[i:])
+			bits[i] = prefix.Bits()
+			t.bits[family] = bits
+		}
+	}
+	t.prefixes[prefix] = value
+}
+
+func (t *prefixTable[T]) search(addr netip.Addr) (T, netip.Prefix, bool) {
+	if addr.IsValid() {
+		addr = addr.WithZone("")
+		for _, bits := range t.bits[familyIndex(addr)] {
+			prefix, _ := addr.Prefix(bits)
+			if value, ok := t.prefixes[prefix]; ok {
+				return value, prefix, true
+			}
+		}
+	}
+	var value T
+	return value, netip.Prefix{}, false
+}
+
+// each calls fn with every prefix and its value.
+func (t *prefixTable[T]) each(fn func(netip.Prefix, T)) {
+	for prefix, value := range t.prefixes {
+		fn(prefix, value)
+	}
+}
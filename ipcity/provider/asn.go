@@ -0,0 +1,40 @@
+package provider
+
+// Names of the ASN data columns.
+const (
+	ColumnASN            = "asn"
+	ColumnASOrganization = "asOrganization"
+	ColumnASPrefix       = "asPrefix"
+)
+
+// ASNSchema is the meta schema of the ASN data.
+var ASNSchema = NewSchema(
+	Column{Name: ColumnASN, Type: ColumnTypeInt},
+	Column{Name: ColumnASOrganization, Type: ColumnTypeString},
+	Column{Name: ColumnASPrefix, Type: ColumnTypeString},
+)
+
+// WithASN returns the meta with the autonomous system number, organization and routed prefix.
+func (r *Meta) WithASN(asn int, organization, prefix string) *Meta {
+	return r.WithField(ColumnASN, asn).
+		WithField(ColumnASOrganization, organization).
+		WithField(ColumnASPrefix, prefix)
+}
+
+// ASN returns the autonomous system number in the meta row information.
+func (r *Meta) ASN() int {
+	v, _ := r.Get(ColumnASN).(int)
+	return v
+}
+
+// ASOrganization returns the autonomous system organization in the meta row information.
+func (r *Meta) ASOrganization() string {
+	v, _ := r.Get(ColumnASOrganization).(string)
+	return v
+}
+
+// ASPrefix returns the routed prefix in the meta row information.
+func (r *Meta) ASPrefix() string {
+	v, _ := r.Get(ColumnASPrefix).(string)
+	return v
+}
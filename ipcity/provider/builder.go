@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StoreBuilder builds a store of the latest data version from the metas of address ranges.
+type StoreBuilder struct {
+	mode              DataMode
+	schema            *Schema
+	sourceUpdatedTime int64
+	segments          []builderSegment
+	metaTable         []*Meta
+	metaIndexes       map[string]uint32
+}
+
+// builderSegment is a range of ip indexes pointing to a meta row.
+type builderSegment struct {
+	first, last  uint64
+	metaRowIndex uint32
+}
+
+// NewStoreBuilder returns a new builder of an IPv4 or IPv6 store.
+func NewStoreBuilder(mode DataMode) *StoreBuilder {
+	return &StoreBuilder{mode: mode, schema: DefaultSchema, metaIndexes: make(map[string]uint32)}
+}
+
+// WithSchema returns the builder with the meta schema, it should be set before adding ranges.
+func (b *StoreBuilder) WithSchema(schema *Schema) *StoreBuilder {
+	if b != nil && schema != nil {
+		b.schema = schema
+	}
+	return b
+}
+
+// WithSourceUpdatedTime returns the builder with the source updated time of the store.
+func (b *StoreBuilder) WithSourceUpdatedTime(updatedTime int64) *StoreBuilder {
+	if b != nil {
+		b.sourceUpdatedTime = updatedTime
+	}
+	return b
+}
+
+// Len returns the count of the added ranges.
+func (b *StoreBuilder) Len() int {
+	if b != nil {
+		return len(b.segments)
+	}
+	return 0
+}
+
+// Add adds the range of a copy of the meta with the builder schema, the empty metas are skipped.
+// An IPv6 range is widened to the /64 networks it touches since the IPv6 ip index is 64 bits.
+func (b *StoreBuilder) Add(r Range, meta *Meta) error {
+	if b == nil {
+		return newNilParamError("StoreBuilder")
+	}
+	if !r.IsValid() {
+		return fmt.Errorf("invalid range %s", r)
+	}
+	var first, last uint64
+	switch {
+	case b.mode == DataModeIPv4 && r.First.Is4():
+		f, l := r.First.As4(), r.Last.As4()
+		first, last = uint64(binary.BigEndian.Uint32(f[:])), uint64(binary.BigEndian.Uint32(l[:]))
+	case b.mode == DataModeIPv6 && r.First.Is6():
+		f, l := r.First.As16(), r.Last.As16()
+		first, last = binary.BigEndian.Uint64(f[:8]), binary.BigEndian.Uint64(l[:8])
+	default:
+		return fmt.Errorf("range %s does not fit the %s store", r, DataModeName[b.mode])
+	}
+	if meta.IsEmpty() {
+		return nil
+	}
+	copied := *meta
+	meta = copied.WithSchema(b.schema)
+	items := meta.marshalItems()
+	for i, item := range items {
+		items[i] = escapeItem(item)
+	}
+	key := strings.Join(items, "\t")
+	index, ok := b.metaIndexes[key]
+	if !ok {
+		if len(b.metaTable) == 0 {
+			// the first meta row is the empty one of the gaps
+			b.metaTable = append(b.metaTable, NewMeta().WithSchema(b.schema))
+		}
+		index = uint32(len(b.metaTable))
+		b.metaIndexes[key] = index
+		b.metaTable = append(b.metaTable, meta)
+	}
+	b.segments = append(b.segments, builderSegment{first: first, last: last, metaRowIndex: index})
+	return nil
+}
+
+// Build returns the store of the added ranges, the gaps between them point to the empty meta,
+// of the overlapping ranges the one starting first wins, or the one added first if they start together.
+func (b *StoreBuilder) Build() *Store {
+	if b == nil {
+		return nil
+	}
+	max := uint64(0xFFFFFFFF)
+	if b.mode == DataModeIPv6 {
+		max = ^uint64(0)
+	}
+	segments := append([]builderSegment(nil), b.segments...)
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].first < segments[j].first })
+
+	var entityList []*Entity
+	add := func(ipIndex uint64, metaRowIndex uint32) {
+		if n := len(entityList); n > 0 && entityList[n-1].MetaRowIndex() == metaRowIndex {
+			return
+		}
+		entityList = append(entityList, NewEntity(ipIndex, metaRowIndex))
+	}
+	next, done := uint64(0), false
+	for _, s := range segments {
+		if done || s.last < next {
+			continue
+		}
+		if s.first < next {
+			s.first = next
+		}
+		if s.first > next {
+			add(next, 0)
+		}
+		add(s.first, s.metaRowIndex)
+		if s.last == max {
+			done = true
+		} else {
+			next = s.last + 1
+		}
+	}
+	if !done {
+		add(next, 0)
+	}
+
+	metaTable := b.metaTable
+	if len(metaTable) == 0 {
+		metaTable = []*Meta{NewMeta().WithSchema(b.schema)}
+	}
+	header := NewHeader(DataVersionLatest, b.mode).
+		WithSchema(b.schema).
+		WithMetaRowCount(uint32(len(metaTable))).
+		WithEntityCount(uint32(len(entityList))).
+		WithSourceUpdatedTime(b.sourceUpdatedTime)
+	return NewStore().
+		WithHeader(header).
+		WithMetaTable(append([]*Meta(nil), metaTable...)).
+		WithEntityList(entityList)
+}
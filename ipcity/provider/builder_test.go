@@ -0,0 +1,22 @@
+package provider
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestStoreBuilder(t *testing.T) {
+	schema := DefaultSchema.WithColumns(Column{Name: ColumnTimeZone, Type: ColumnTypeString})
+	builder := NewStoreBuilder(DataModeIPv4).WithSchema(schema)
+	meta := NewMeta().WithCity("广州")
+	r := Range{First: netip.MustParseAddr("1.0.0.0"), Last: netip.MustParseAddr("1.255.255.255")}
+	if err := builder.Add(r, meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Schema() != DefaultSchema {
+		t.Error("builder changed the schema of the added meta")
+	}
+	if built := builder.Build().SearchAddr(netip.MustParseAddr("1.2.3.4")); built.City() != "广州" || built.Schema() != schema {
+		t.Errorf("unexpected meta %s", built)
+	}
+}
@@ -0,0 +1,235 @@
+package provider
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+)
+
+// ChangeType is the type of a range change between two stores.
+type ChangeType byte
+
+const (
+	// ChangeAdded means the range has a meta only in the new store.
+	ChangeAdded = ChangeType(1)
+	// ChangeRemoved means the range has a meta only in the old store.
+	ChangeRemoved = ChangeType(2)
+	// ChangeReassigned means the range has different metas in the stores.
+	ChangeReassigned = ChangeType(3)
+)
+
+// ChangeTypeName is a mapping for change type name.
+var ChangeTypeName = map[ChangeType]string{
+	ChangeAdded:      "added",
+	ChangeRemoved:    "removed",
+	ChangeReassigned: "reassigned",
+}
+
+func (t ChangeType) String() string {
+	return ChangeTypeName[t]
+}
+
+// MarshalText marshals the change type by its name.
+func (t ChangeType) MarshalText() ([]byte, error) {
+	if name, ok := ChangeTypeName[t]; ok {
+		return []byte(name), nil
+	}
+	return nil, fmt.Errorf("unknown change type %d", t)
+}
+
+// FieldChange defines a meta field changed in a reassigned range.
+type FieldChange struct {
+	Name string      `json:"name"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// RangeChange defines a range of the same old and new metas which differ, the empty meta is nil.
+type RangeChange struct {
+	Type   ChangeType    `json:"type"`
+	First  netip.Addr    `json:"first"`
+	Last   netip.Addr    `json:"last"`
+	Old    *Meta         `json:"old,omitempty"`
+	New    *Meta         `json:"new,omitempty"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// Range returns the address range of the change.
+func (c RangeChange) Range() Range {
+	return Range{First: c.First, Last: c.Last}
+}
+
+// AffectedCount defines the ranges and addresses changed of a country or an ISP.
+type AffectedCount struct {
+	Name      string   `json:"name"`
+	Ranges    int      `json:"ranges"`
+	Addresses *big.Int `json:"addresses"`
+}
+
+// StoreDiff defines the changes from an old store to a new one.
+type StoreDiff struct {
+	Changes    []RangeChange `json:"changes"`
+	Added      int           `json:"added"`
+	Removed    int           `json:"removed"`
+	Reassigned int           `json:"reassigned"`
+	// Addresses is the count of the changed addresses
+	Addresses *big.Int `json:"addresses"`
+	// Countries and ISPs count the changes by the country and ISP of the old and the new meta,
+	// a change moving between two of them counts for both, the most addresses first
+	Countries []AffectedCount `json:"countries"`
+	ISPs      []AffectedCount `json:"isps"`
+}
+
+// Diff returns the changes from the old store to the new one, the stores must be of the same address family.
+func Diff(old, new *Store) (*StoreDiff, error) {
+	if old.Header().AddrBitLen() == 0 || old.Header().AddrBitLen() != new.Header().AddrBitLen() {
+		return nil, fmt.Errorf("can not diff %s store with %s store", old.Header().ModeName(), new.Header().ModeName())
+	}
+	diff := &StoreDiff{Changes: []RangeChange{}, Addresses: big.NewInt(0)}
+	// the adjacent ranges of the same meta rows are merged into the last change
+	var lastKey [2]int
+	emit := func(r Range, oldIndex, newIndex int) {
+		key := [2]int{oldIndex, newIndex}
+		if n := len(diff.Changes); n > 0 && lastKey == key && diff.Changes[n-1].Last.Next() == r.First {
+			diff.Changes[n-1].Last = r.Last
+			return
+		}
+		change, ok := diffMeta(old.Meta(oldIndex), new.Meta(newIndex))
+		if !ok {
+			return
+		}
+		change.First, change.Last = r.First, r.Last
+		diff.Changes = append(diff.Changes, change)
+		lastKey = key
+	}
+
+	// sweep the entity ranges of both stores in address order, starting at the addresses
+	// before their first entities
+	for i, j := diffStart(old), diffStart(new); i < old.EntityCount() && j < new.EntityCount(); {
+		a, oldIndex := diffRange(old, i)
+		b, newIndex := diffRange(new, j)
+		first, last := a.First, a.Last
+		if first.Less(b.First) {
+			first = b.First
+		}
+		if b.Last.Less(last) {
+			last = b.Last
+		}
+		if !last.Less(first) {
+			emit(Range{First: first, Last: last}, oldIndex, newIndex)
+		}
+		if a.Last == last {
+			i++
+		}
+		if b.Last == last {
+			j++
+		}
+	}
+
+	countries := make(map[string]*AffectedCount)
+	isps := make(map[string]*AffectedCount)
+	count := func(counts map[string]*AffectedCount, size *big.Int, name func(*Meta) string, change RangeChange) {
+		var names []string
+		for _, meta := range []*Meta{change.Old, change.New} {
+			if meta != nil && (len(names) == 0 || names[0] != name(meta)) {
+				names = append(names, name(meta))
+			}
+		}
+		for _, n := range names {
+			c, ok := counts[n]
+			if !ok {
+				c = &AffectedCount{Name: n, Addresses: big.NewInt(0)}
+				counts[n] = c
+			}
+			c.Ranges++
+			c.Addresses.Add(c.Addresses, size)
+		}
+	}
+	for _, change := range diff.Changes {
+		switch change.Type {
+		case ChangeAdded:
+			diff.Added++
+		case ChangeRemoved:
+			diff.Removed++
+		case ChangeReassigned:
+			diff.Reassigned++
+		}
+		size := change.Range().Size()
+		diff.Addresses.Add(diff.Addresses, size)
+		count(countries, size, (*Meta).Country, change)
+		count(isps, size, (*Meta).ISP, change)
+	}
+	diff.Countries, diff.ISPs = sortAffectedCounts(countries), sortAffectedCounts(isps)
+	return diff, nil
+}
+
+// diffStart returns the first entity index of the sweep, -1 if there are addresses before the first entity.
+func diffStart(s *Store) int {
+	if first := s.Entity(0); first != nil && first.IPIndex() == 0 {
+		return 0
+	}
+	return -1
+}
+
+// diffRange returns the range and the meta row index of the entity i, the entity -1 is
+// the addresses before the first entity and has no meta row.
+func diffRange(s *Store, i int) (Range, int) {
+	if i >= 0 {
+		return s.EntityRange(i), int(s.Entity(i).MetaRowIndex())
+	}
+	r := Range{First: s.ipIndexAddr(0, false), Last: s.ipIndexAddr(s.maxIPIndex(), true)}
+	if first := s.Entity(0); first != nil {
+		r.Last = s.ipIndexAddr(first.IPIndex()-1, true)
+	}
+	return r, -1
+}
+
+// diffMeta returns the change between the metas, false if they are equal.
+func diffMeta(old, new *Meta) (RangeChange, bool) {
+	switch {
+	case old.IsEmpty() && new.IsEmpty():
+		return RangeChange{}, false
+	case old.IsEmpty():
+		return RangeChange{Type: ChangeAdded, New: new}, true
+	case new.IsEmpty():
+		return RangeChange{Type: ChangeRemoved, Old: old}, true
+	}
+	change := RangeChange{Type: ChangeReassigned, Old: old, New: new}
+	names := make(map[string]bool)
+	for _, fields := range [][]Field{old.Fields(), new.Fields()} {
+		for _, field := range fields {
+			if names[field.Name] {
+				continue
+			}
+			names[field.Name] = true
+			o, n := old.Get(field.Name), new.Get(field.Name)
+			if fieldText(o) != fieldText(n) {
+				change.Fields = append(change.Fields, FieldChange{Name: field.Name, Old: o, New: n})
+			}
+		}
+	}
+	return change, len(change.Fields) > 0
+}
+
+// fieldText returns the text form of the value, empty for the zero values and the missing ones.
+func fieldText(v interface{}) string {
+	if v == nil || v == zeroValue(valueType(v)) {
+		return ""
+	}
+	return formatValue(valueType(v), v)
+}
+
+func sortAffectedCounts(counts map[string]*AffectedCount) []AffectedCount {
+	sorted := make([]AffectedCount, 0, len(counts))
+	for _, c := range counts {
+		sorted = append(sorted, *c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].Addresses.Cmp(sorted[j].Addresses); c != 0 {
+			return c > 0
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
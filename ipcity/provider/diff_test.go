@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := newTestIPv4Store()
+	updated := newTestStore(DataModeIPv4,
+		[]uint64{0x00000000, 0x01000000, 0x01800000, 0x02000000, 0x03000000, 0x04000000},
+		[]*Meta{
+			NewMeta(),
+			NewMeta().WithCountry("中国").WithProvince("广东").WithCity("广州").WithISP("电信"),
+			NewMeta().WithCountry("中国").WithProvince("广东").WithCity("深圳").WithISP("电信"),
+			NewMeta(),
+			NewMeta().WithCountry("美国").WithCountryCode(1),
+			NewMeta(),
+		})
+	diff, err := Diff(old, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []string
+	for _, change := range diff.Changes {
+		changes = append(changes, fmt.Sprintf("%s %s %v", change.Type, change.Range(), change.Fields))
+	}
+	if got, want := strings.Join(changes, "; "), "reassigned 1.128.0.0-1.255.255.255 [{city 广州 深圳}]; "+
+		"removed 2.0.0.0-2.255.255.255 []; added 3.0.0.0-3.255.255.255 []"; got != want {
+		t.Errorf("unexpected changes %s, want %s", got, want)
+	}
+	if diff.Added != 1 || diff.Removed != 1 || diff.Reassigned != 1 || diff.Addresses.Int64() != 0x2800000 {
+		t.Errorf("unexpected counts %d %d %d %s", diff.Added, diff.Removed, diff.Reassigned, diff.Addresses)
+	}
+	if got := fmt.Sprint(diff.Countries, diff.ISPs); got != "[{美国 2 33554432} {中国 1 8388608}] [{ 2 33554432} {电信 1 8388608}]" {
+		t.Errorf("unexpected affected counts %s", got)
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"type":"reassigned","first":"1.128.0.0","last":"1.255.255.255","old":{"country":"中国"`)) {
+		t.Errorf("unexpected json %s", data)
+	}
+
+	ipv6 := newTestIPv6Store()
+	if _, err = Diff(old, ipv6); err == nil {
+		t.Error("diff IPv4 with IPv6 got no error")
+	}
+	if diff, err = Diff(ipv6, ipv6); err != nil || len(diff.Changes) != 0 || diff.Addresses.Sign() != 0 {
+		t.Errorf("unexpected diff of the same store %v %v", diff, err)
+	}
+}
+
+func TestDiffLeadingRange(t *testing.T) {
+	// the old store has no entity before 1.0.0.0, the new one covers 0.0.0.0/8
+	old := newTestStore(DataModeIPv4,
+		[]uint64{0x01000000, 0x02000000},
+		[]*Meta{NewMeta().WithCity("广州"), NewMeta()})
+	updated := newTestStore(DataModeIPv4,
+		[]uint64{0x00000000, 0x01000000, 0x02000000},
+		[]*Meta{NewMeta().WithCity("深圳"), NewMeta().WithCity("广州"), NewMeta()})
+	for _, c := range []struct {
+		old, new *Store
+		want     string
+	}{
+		{old, updated, "added 0.0.0.0-0.255.255.255"},
+		{updated, old, "removed 0.0.0.0-0.255.255.255"},
+		{NewStore().WithHeader(NewHeader(DataVersionLatest, DataModeIPv4)), old, "added 1.0.0.0-1.255.255.255"},
+	} {
+		diff, err := Diff(c.old, c.new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var changes []string
+		for _, change := range diff.Changes {
+			changes = append(changes, fmt.Sprintf("%s %s", change.Type, change.Range()))
+		}
+		if got := strings.Join(changes, "; "); got != c.want {
+			t.Errorf("unexpected changes %s, want %s", got, c.want)
+		}
+	}
+}
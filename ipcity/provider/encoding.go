@@ -0,0 +1,318 @@
+package provider
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+var (
+	_ json.Marshaler             = (*Meta)(nil)
+	_ json.Unmarshaler           = (*Meta)(nil)
+	_ encoding.TextMarshaler     = (*Meta)(nil)
+	_ encoding.TextUnmarshaler   = (*Meta)(nil)
+	_ encoding.BinaryMarshaler   = (*Meta)(nil)
+	_ encoding.BinaryUnmarshaler = (*Meta)(nil)
+
+	_ json.Marshaler             = (*Header)(nil)
+	_ json.Unmarshaler           = (*Header)(nil)
+	_ encoding.TextMarshaler     = (*Header)(nil)
+	_ encoding.BinaryMarshaler   = (*Header)(nil)
+	_ encoding.BinaryUnmarshaler = (*Header)(nil)
+
+	_ encoding.BinaryMarshaler   = (*Store)(nil)
+	_ encoding.BinaryUnmarshaler = (*Store)(nil)
+	_ io.WriterTo                = (*Store)(nil)
+	_ io.ReaderFrom              = (*Store)(nil)
+)
+
+// MarshalText returns the name of the column type.
+func (t ColumnType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText parses the name of the column type.
+func (t *ColumnType) UnmarshalText(text []byte) error {
+	for columnType, name := range ColumnTypeName {
+		if name == string(text) {
+			*t = columnType
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown column type %q", text)
+}
+
+// MarshalText returns the name of the data mode.
+func (m DataMode) MarshalText() ([]byte, error) {
+	if name, ok := DataModeName[m]; ok {
+		return []byte(name), nil
+	}
+	return nil, fmt.Errorf("unknown data mode %d", m)
+}
+
+// UnmarshalText parses the name of the data mode.
+func (m *DataMode) UnmarshalText(text []byte) error {
+	for mode, name := range DataModeName {
+		if name == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown data mode %q", text)
+}
+
+// MarshalJSON encodes the schema as the ordered list of columns.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type column struct {
+		Name string     `json:"name"`
+		Type ColumnType `json:"type"`
+	}
+	columns := make([]column, 0, s.Len())
+	for _, c := range s.Columns() {
+		columns = append(columns, column{Name: c.Name, Type: c.Type})
+	}
+	return json.Marshal(columns)
+}
+
+// UnmarshalJSON decodes the ordered list of columns.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var columns []struct {
+		Name string     `json:"name"`
+		Type ColumnType `json:"type"`
+	}
+	if err := json.Unmarshal(data, &columns); err != nil {
+		return err
+	}
+	schema := make([]Column, 0, len(columns))
+	for _, c := range columns {
+		schema = append(schema, Column{Name: c.Name, Type: c.Type})
+	}
+	*s = *NewSchema(schema...)
+	return nil
+}
+
+// MarshalJSON encodes the meta as an object of the fields in schema order.
+func (r *Meta) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString("{")
+	for i, field := range r.Fields() {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, fmt.Errorf("marshal meta field %s error, %s", field.Name, err)
+		}
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalJSON decodes an object of fields into the meta, the values are converted
+// to the types of the declared columns.
+func (r *Meta) UnmarshalJSON(data []byte) error {
+	if r == nil {
+		return newNilParamError("Meta")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return err
+	}
+	for name, value := range fields {
+		columnType := ColumnTypeUnknown
+		if i := r.Schema().Index(name); i >= 0 {
+			columnType = r.Schema().Column(i).Type
+		} else if i = DefaultSchema.Index(name); i >= 0 {
+			columnType = DefaultSchema.Column(i).Type
+		}
+		v, err := jsonValue(columnType, value)
+		if err != nil {
+			return fmt.Errorf("unmarshal meta field %s error, %s", name, err)
+		}
+		r.WithField(name, v)
+	}
+	return nil
+}
+
+// jsonValue converts a decoded json value to the column type,
+// the type is inferred from the value if unknown.
+func jsonValue(t ColumnType, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return zeroValue(t), nil
+	case string:
+		if t == ColumnTypeUnknown || t == ColumnTypeString {
+			return v, nil
+		}
+		return parseValue(t, v)
+	case json.Number:
+		switch t {
+		case ColumnTypeString:
+			return v.String(), nil
+		case ColumnTypeFloat:
+			return v.Float64()
+		case ColumnTypeInt:
+			return strconv.Atoi(v.String())
+		default:
+			if i, err := strconv.Atoi(v.String()); err == nil {
+				return i, nil
+			}
+			return v.Float64()
+		}
+	default:
+		return nil, fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// MarshalText encodes the meta as the escaped tab separated row of the latest data version.
+func (r *Meta) MarshalText() ([]byte, error) {
+	line, err := (&MetaMarshaler{DataVersion: DataVersionLatest}).MarshalString(r)
+	return []byte(line), err
+}
+
+// UnmarshalText decodes the escaped tab separated row of the latest data version by the meta schema.
+func (r *Meta) UnmarshalText(text []byte) error {
+	return (&MetaUnmarshaler{DataVersion: DataVersionLatest}).UnmarshalString(string(text), r)
+}
+
+// MarshalBinary encodes the meta as the row of the latest data version.
+func (r *Meta) MarshalBinary() ([]byte, error) {
+	return r.MarshalText()
+}
+
+// UnmarshalBinary decodes the row of the latest data version by the meta schema.
+func (r *Meta) UnmarshalBinary(data []byte) error {
+	return r.UnmarshalText(data)
+}
+
+// headerJSON is the json form of the header, zero index sizes mean the defaults of the version.
+type headerJSON struct {
+	Version           DataVersion `json:"version"`
+	Mode              DataMode    `json:"mode"`
+	IPIndexSize       byte        `json:"ipIndexSize,omitempty"`
+	MetaRowIndexSize  byte        `json:"metaRowIndexSize,omitempty"`
+	MetaRowCount      uint32      `json:"metaRowCount"`
+	EntityCount       uint32      `json:"entityCount"`
+	SourceUpdatedTime time.Time   `json:"sourceUpdatedTime"`
+	UpdatedTime       time.Time   `json:"updatedTime"`
+	Schema            *Schema     `json:"schema"`
+}
+
+// MarshalJSON encodes the header as an object.
+func (h *Header) MarshalJSON() ([]byte, error) {
+	if h == nil || h.impl == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(headerJSON{
+		Version:           h.impl.Version,
+		Mode:              h.impl.Mode,
+		IPIndexSize:       h.impl.IPIndexSize,
+		MetaRowIndexSize:  h.impl.MetaRowIndexSize,
+		MetaRowCount:      h.impl.MetaRowCount,
+		EntityCount:       h.impl.EntityCount,
+		SourceUpdatedTime: h.SourceUpdatedTime().UTC(),
+		UpdatedTime:       h.UpdatedTime().UTC(),
+		Schema:            h.Schema(),
+	})
+}
+
+// UnmarshalJSON decodes the object of the header.
+func (h *Header) UnmarshalJSON(data []byte) error {
+	if h == nil {
+		return newNilParamError("Header")
+	}
+	var v headerJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	h.impl = &headerImpl{
+		Version:           v.Version,
+		Mode:              v.Mode,
+		IPIndexSize:       v.IPIndexSize,
+		MetaRowIndexSize:  v.MetaRowIndexSize,
+		MetaRowCount:      v.MetaRowCount,
+		EntityCount:       v.EntityCount,
+		SourceUpdatedTime: uint32(v.SourceUpdatedTime.Unix()),
+		UpdatedTime:       uint32(v.UpdatedTime.Unix()),
+		Schema:            v.Schema,
+	}
+	return nil
+}
+
+// MarshalText returns the readable form of the header.
+func (h *Header) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// MarshalBinary encodes the header of the data file.
+func (h *Header) MarshalBinary() ([]byte, error) {
+	return h.Marshal()
+}
+
+// UnmarshalBinary decodes the header of the data file.
+func (h *Header) UnmarshalBinary(data []byte) error {
+	if h == nil {
+		return newNilParamError("Header")
+	}
+	if h.impl == nil {
+		h.impl = &headerImpl{}
+	}
+	return h.Unmarshal(data)
+}
+
+// MarshalBinary encodes the store as a data file.
+func (s *Store) MarshalBinary() ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if _, err := s.MarshalTo(buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalBinary decodes the data file into the store.
+func (s *Store) UnmarshalBinary(data []byte) error {
+	if s == nil {
+		return newNilParamError("Store")
+	}
+	return s.UnmarshalFrom(bytes.NewReader(data))
+}
+
+// WriteTo writes the store as a data file.
+func (s *Store) WriteTo(w io.Writer) (int64, error) {
+	n, err := s.MarshalTo(w)
+	return int64(n), err
+}
+
+// ReadFrom reads the data file into the store until EOF.
+func (s *Store) ReadFrom(r io.Reader) (int64, error) {
+	if s == nil {
+		return 0, newNilParamError("Store")
+	}
+	counter := &countingReader{reader: r}
+	err := s.UnmarshalFrom(counter)
+	return counter.n, err
+}
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
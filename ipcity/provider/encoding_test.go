@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// newTestStore returns a store of the metas starting at the ip indexes.
+func newTestStore(mode DataMode, ipIndexes []uint64, metas []*Meta) *Store {
+	entityList := make([]*Entity, 0, len(ipIndexes))
+	for i, ipIndex := range ipIndexes {
+		entityList = append(entityList, NewEntity(ipIndex, uint32(i)))
+	}
+	return NewStore().
+		WithHeader(NewHeader(DataVersionLatest, mode)).
+		WithMetaTable(metas).
+		WithEntityList(entityList)
+}
+
+// newTestIPv4Store returns an IPv4 store of 1.0.0.0/8 in 广州 and 2.0.0.0/8 in 美国.
+func newTestIPv4Store() *Store {
+	return newTestStore(DataModeIPv4,
+		[]uint64{0x00000000, 0x01000000, 0x02000000, 0x03000000},
+		[]*Meta{
+			NewMeta(),
+			NewMeta().WithCountry("中国").WithProvince("广东").WithCity("广州").WithISP("电信"),
+			NewMeta().WithCountry("美国").WithCountryCode(1),
+			NewMeta(),
+		})
+}
+
+// newTestIPv6Store returns an IPv6 store of 2400::/8 in 北京.
+func newTestIPv6Store() *Store {
+	return newTestStore(DataModeIPv6,
+		[]uint64{0x0000000000000000, 0x2400000000000000, 0x2500000000000000},
+		[]*Meta{
+			NewMeta(),
+			NewMeta().WithCountry("中国").WithCity("北京"),
+			NewMeta(),
+		})
+}
+
+func TestStoreEncoding(t *testing.T) {
+	data, err := newTestIPv4Store().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore()
+	if err = store.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := store.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("unexpected encoded store %X, want %X", encoded, data)
+	}
+	decoded := NewStore()
+	if n, err := decoded.ReadFrom(bytes.NewReader(encoded)); err != nil || n != int64(len(encoded)) {
+		t.Fatalf("read store %d bytes error, %v", n, err)
+	}
+	if meta := decoded.SearchAddr(netip.MustParseAddr("1.2.3.4")); meta.City() != "广州" {
+		t.Errorf("unexpected meta %s", meta)
+	}
+
+	header := &Header{}
+	if b, err := json.Marshal(store.Header()); err != nil {
+		t.Fatal(err)
+	} else if err = json.Unmarshal(b, header); err != nil {
+		t.Fatal(err)
+	}
+	if header.String() != store.Header().String() {
+		t.Errorf("unexpected header %s, want %s", header, store.Header())
+	}
+
+	meta := store.Meta(2)
+	b, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), `{"country":"美国","province":""`) {
+		t.Errorf("unexpected meta json %s", b)
+	}
+	decodedMeta := &Meta{}
+	if err = json.Unmarshal(b, decodedMeta); err != nil {
+		t.Fatal(err)
+	}
+	if decodedMeta.String() != meta.String() || decodedMeta.CountryCode() != 1 {
+		t.Errorf("unexpected meta %s, want %s", decodedMeta, meta)
+	}
+
+	text, err := meta.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedMeta = NewMeta()
+	if err = decodedMeta.UnmarshalText(text); err != nil || decodedMeta.String() != meta.String() {
+		t.Errorf("unexpected meta %s from text %q, %v", decodedMeta, text, err)
+	}
+}
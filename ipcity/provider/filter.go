@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MatchType is the type of a field condition.
+type MatchType byte
+
+const (
+	// MatchExact matches the field equal to the value.
+	MatchExact = MatchType(0)
+	// MatchPrefix matches the field starting with the value.
+	MatchPrefix = MatchType(1)
+	// MatchRegexp matches the field by the regular expression.
+	MatchRegexp = MatchType(2)
+)
+
+// MatchTypeName is a mapping for match type name.
+var MatchTypeName = map[MatchType]string{
+	MatchExact:  "exact",
+	MatchPrefix: "prefix",
+	MatchRegexp: "regexp",
+}
+
+func (t MatchType) String() string {
+	return MatchTypeName[t]
+}
+
+// matchOperators are the operators of the filter expressions, longest first.
+var matchOperators = []struct {
+	operator string
+	match    MatchType
+}{
+	{"^=", MatchPrefix},
+	{"~=", MatchRegexp},
+	{"=", MatchExact},
+}
+
+// Condition defines a condition on a meta field.
+type Condition struct {
+	Field  string
+	Type   MatchType
+	Value  string
+	regexp *regexp.Regexp
+}
+
+// Match returns true if the field of the meta satisfies the condition,
+// the numeric fields are compared by their text form.
+func (c Condition) Match(meta *Meta) bool {
+	v := meta.Get(c.Field)
+	if v == nil {
+		return false
+	}
+	s, ok := v.(string)
+	if !ok {
+		s = formatValue(valueType(v), v)
+	}
+	switch c.Type {
+	case MatchPrefix:
+		return strings.HasPrefix(s, c.Value)
+	case MatchRegexp:
+		return c.regexp != nil && c.regexp.MatchString(s)
+	default:
+		return s == c.Value
+	}
+}
+
+func (c Condition) String() string {
+	for _, op := range matchOperators {
+		if op.match == c.Type {
+			return c.Field + op.operator + c.Value
+		}
+	}
+	return ""
+}
+
+// Filter matches the meta rows satisfying all of its conditions.
+type Filter struct {
+	conditions []Condition
+}
+
+// NewFilter returns a new filter matching all the meta rows.
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+// WithExact returns the filter with the condition the field equals to the value.
+func (f *Filter) WithExact(field, value string) *Filter {
+	if f != nil {
+		f.conditions = append(f.conditions, Condition{Field: field, Type: MatchExact, Value: value})
+	}
+	return f
+}
+
+// WithPrefix returns the filter with the condition the field starts with the value.
+func (f *Filter) WithPrefix(field, value string) *Filter {
+	if f != nil {
+		f.conditions = append(f.conditions, Condition{Field: field, Type: MatchPrefix, Value: value})
+	}
+	return f
+}
+
+// WithRegexp returns the filter with the condition the field matches the regular expression.
+func (f *Filter) WithRegexp(field string, re *regexp.Regexp) *Filter {
+	if f != nil && re != nil {
+		f.conditions = append(f.conditions, Condition{Field: field, Type: MatchRegexp, Value: re.String(), regexp: re})
+	}
+	return f
+}
+
+// Conditions returns a copy of the conditions of the filter.
+func (f *Filter) Conditions() []Condition {
+	if f != nil {
+		return append([]Condition(nil), f.conditions...)
+	}
+	return nil
+}
+
+// Match returns true if the meta satisfies all the conditions, a nil filter matches all.
+func (f *Filter) Match(meta *Meta) bool {
+	if f == nil {
+		return true
+	}
+	for _, c := range f.conditions {
+		if !c.Match(meta) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	items := make([]string, 0, len(f.conditions))
+	for _, c := range f.conditions {
+		items = append(items, c.String())
+	}
+	return strings.Join(items, " ")
+}
+
+// ParseFilter parses the expressions into a filter, an expression is "field=value"
+// for exact match, "field^=value" for prefix match or "field~=regexp" for regexp match.
+func ParseFilter(expressions ...string) (*Filter, error) {
+	f := NewFilter()
+	for _, expression := range expressions {
+		i := strings.IndexAny(expression, "^~=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid filter %q, want field=value, field^=value or field~=regexp", expression)
+		}
+		field, rest := expression[:i], expression[i:]
+		parsed := false
+		for _, op := range matchOperators {
+			if !strings.HasPrefix(rest, op.operator) {
+				continue
+			}
+			value := strings.TrimPrefix(rest, op.operator)
+			switch op.match {
+			case MatchExact:
+				f.WithExact(field, value)
+			case MatchPrefix:
+				f.WithPrefix(field, value)
+			case MatchRegexp:
+				re, err := regexp.Compile(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid filter %q, %s", expression, err)
+				}
+				f.WithRegexp(field, re)
+			}
+			parsed = true
+			break
+		}
+		if !parsed {
+			return nil, fmt.Errorf("invalid filter %q, want field=value, field^=value or field~=regexp", expression)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	store := newTestStore(DataModeIPv4,
+		[]uint64{0x00000000, 0x01000000, 0x01800000, 0x01C00000, 0x02000000, 0x03000000},
+		[]*Meta{
+			NewMeta(),
+			NewMeta().WithProvince("广东").WithCity("广州").WithISP("电信"),
+			NewMeta().WithProvince("广东").WithCity("广州").WithISP("移动"),
+			NewMeta().WithProvince("广东").WithCity("广州").WithISP("电信"),
+			NewMeta().WithCountry("美国").WithCountryCode(1),
+			NewMeta(),
+		})
+	for _, c := range []struct {
+		expressions []string
+		want        string
+	}{
+		{[]string{"city=广州"}, "[1.0.0.0/8]"},
+		{[]string{"province^=广", "isp=电信"}, "[1.0.0.0/9 1.192.0.0/10]"},
+		{[]string{"isp~=^移"}, "[1.128.0.0/10]"},
+		{[]string{"countryCode=1"}, "[2.0.0.0/8]"},
+		{[]string{"city=深圳"}, "[]"},
+	} {
+		filter, err := ParseFilter(c.expressions...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prefixes []netip.Prefix
+		for _, r := range store.Ranges(filter) {
+			prefixes = append(prefixes, r.Prefixes()...)
+		}
+		if got := fmt.Sprint(prefixes); got != c.want {
+			t.Errorf("unexpected ranges of %s %s, want %s", filter, got, c.want)
+		}
+	}
+	for _, expression := range []string{"isp", "=电信", "isp~=("} {
+		if _, err := ParseFilter(expression); err == nil {
+			t.Errorf("expected invalid filter error of %q", expression)
+		}
+	}
+
+	filter := NewFilter().WithExact("city", "广州").WithRegexp("isp", regexp.MustCompile("^电"))
+	if got := filter.String(); got != "city=广州 isp~=^电" {
+		t.Errorf("unexpected filter %s", got)
+	}
+	if !filter.Match(store.Meta(1)) || filter.Match(store.Meta(2)) || filter.Match(nil) {
+		t.Errorf("unexpected match of %s", filter)
+	}
+	filter.Conditions()[0].Value = "深圳"
+	if !filter.Match(store.Meta(1)) {
+		t.Error("conditions are not copied")
+	}
+	var nilFilter *Filter
+	if !nilFilter.Match(store.Meta(0)) || nilFilter.String() != "" || nilFilter.Conditions() != nil {
+		t.Error("unexpected nil filter")
+	}
+
+	r := Range{First: netip.MustParseAddr("1.0.0.5"), Last: netip.MustParseAddr("1.0.0.9")}
+	if got := fmt.Sprint(r.Prefixes()); got != "[1.0.0.5/32 1.0.0.6/31 1.0.0.8/31]" {
+		t.Errorf("unexpected prefixes %s", got)
+	}
+}
@@ -19,8 +19,12 @@ type DataVersion byte
 const (
 	// DataVersionUnknown is the unknown ipcity date version.
 	DataVersionUnknown = DataVersion(0)
+	// DataVersionSchema is the first version declaring the meta schema in the header.
+	DataVersionSchema = DataVersion(4)
+	// DataVersionEscaped is the first version escaping the separators in the meta rows.
+	DataVersionEscaped = DataVersion(5)
 	// DataVersionLatest is latest version of the ipcity data.
-	DataVersionLatest = DataVersion(3)
+	DataVersionLatest = DataVersion(5)
 )
 
 // DataMode is the data mode type.
@@ -33,6 +37,9 @@ const (
 	DataModeIPv4 = DataMode(1)
 	// DataModeIPv6 means this file is a IPv6 data file.
 	DataModeIPv6 = DataMode(2)
+	// DataModeASN means this file is an ASN data file, its IP index size
+	// is 4 for IPv4 ranges or 8 for IPv6 ranges.
+	DataModeASN = DataMode(3)
 )
 
 // DataModeName is a mapping for data mode name.
@@ -40,6 +47,7 @@ var DataModeName = map[DataMode]string{
 	DataModeUnknown: "Unknown",
 	DataModeIPv4:    "IPv4",
 	DataModeIPv6:    "IPv6",
+	DataModeASN:     "ASN",
 }
 
 var (
@@ -55,9 +63,10 @@ type headerImpl struct {
 	EntityCount       uint32
 	SourceUpdatedTime uint32
 	UpdatedTime       uint32
+	Schema            *Schema
 }
 
-func (h *headerImpl) ReadFrom(r io.Reader) error {
+func (h *headerImpl) readFrom(r io.Reader) error {
 	if h == nil {
 		return fmt.Errorf("init <nil> header")
 	}
@@ -77,7 +86,14 @@ func (h *headerImpl) ReadFrom(r io.Reader) error {
 	h.EntityCount = binary.BigEndian.Uint32(buffer[12:16])
 	h.SourceUpdatedTime = binary.BigEndian.Uint32(buffer[16:20])
 	h.UpdatedTime = binary.BigEndian.Uint32(buffer[20:])
-	return nil
+	if h.Version < DataVersionSchema {
+		return nil
+	}
+
+	// the meta schema follows the fixed header since version 4
+	h.Schema = &Schema{}
+	_, err := h.Schema.readFrom(r)
+	return err
 }
 
 func (h *headerImpl) WriteTo(w io.Writer) (int64, error) {
@@ -107,6 +123,16 @@ func (h *headerImpl) WriteTo(w io.Writer) (int64, error) {
 		func() error { return binary.Write(buffer, binary.BigEndian, h.EntityCount) },
 		func() error { return binary.Write(buffer, binary.BigEndian, h.SourceUpdatedTime) },
 		func() error { return binary.Write(buffer, binary.BigEndian, h.UpdatedTime) },
+		func() error {
+			if h.Version < DataVersionSchema {
+				return nil
+			}
+			schema := h.Schema
+			if schema == nil {
+				schema = DefaultSchema
+			}
+			return schema.writeTo(buffer)
+		},
 	); err != nil {
 		return 0, err
 	}
@@ -156,6 +182,22 @@ func (h *Header) WithUpdatedTime(updatedTime int64) *Header {
 	return h
 }
 
+// WithSchema returns the data header with meta schema, only written since version 4.
+func (h *Header) WithSchema(schema *Schema) *Header {
+	if h != nil && h.impl != nil {
+		h.impl.Schema = schema
+	}
+	return h
+}
+
+// WithIPIndexSize returns the data header with the IP index size.
+func (h *Header) WithIPIndexSize(size uint32) *Header {
+	if h != nil && h.impl != nil && validateIPIndexSize(size) {
+		h.impl.IPIndexSize = byte(size)
+	}
+	return h
+}
+
 // SetMetaRowCount set city count of the data header.
 func (h *Header) SetMetaRowCount(metaRowCount uint32) {
 	if h != nil && h.impl != nil {
@@ -196,6 +238,14 @@ func (h *Header) ModeName() string {
 	return DataModeName[DataModeUnknown]
 }
 
+// Schema returns the meta schema of the ipcity data, DefaultSchema before version 4.
+func (h *Header) Schema() *Schema {
+	if h != nil && h.impl != nil && h.impl.Schema != nil && h.Version() >= DataVersionSchema {
+		return h.impl.Schema
+	}
+	return DefaultSchema
+}
+
 // MetaRowCount returns the city count in the ipcity data.
 func (h *Header) MetaRowCount() uint32 {
 	if h != nil && h.impl != nil {
@@ -218,6 +268,14 @@ var (
 			DataModeIPv4: 4,
 			DataModeIPv6: 8,
 		},
+		DataVersion(4): map[DataMode]uint32{
+			DataModeIPv4: 4,
+			DataModeIPv6: 8,
+		},
+		DataVersion(5): map[DataMode]uint32{
+			DataModeIPv4: 4,
+			DataModeIPv6: 8,
+		},
 	}
 )
 
@@ -237,6 +295,24 @@ func (h *Header) IPIndexSize() uint32 {
 	return 0
 }
 
+// AddrBitLen returns the address bit length of the data, 32 for IPv4 and 128 for IPv6.
+func (h *Header) AddrBitLen() int {
+	switch h.Mode() {
+	case DataModeIPv4:
+		return 32
+	case DataModeIPv6:
+		return 128
+	case DataModeASN:
+		switch h.IPIndexSize() {
+		case 4:
+			return 32
+		case 8:
+			return 128
+		}
+	}
+	return 0
+}
+
 func belong(value, min, max uint32) bool {
 	return value >= min && value <= max
 }
@@ -250,7 +326,7 @@ func (h *Header) MetaRowIndexSize() uint32 {
 
 		// default MetaRowIndex size
 		switch h.Version() {
-		case DataVersion(3):
+		case DataVersion(3), DataVersion(4), DataVersion(5):
 			switch {
 			case belong(h.MetaRowCount(), 0, 0x000000FF):
 				return 1
@@ -293,7 +369,7 @@ func (h *Header) String() string {
 			"{version:%d mode:%s "+
 				"ipIndexSize:%d metaRowIndexSize:%d "+
 				"metaRowCount:%d entityCount:%d "+
-				"sourceUpdatedTime:%q updatedTime:%q}",
+				"sourceUpdatedTime:%q updatedTime:%q schema:%s}",
 			h.Version(),
 			h.ModeName(),
 			h.IPIndexSize(),
@@ -306,7 +382,8 @@ func (h *Header) String() string {
 				}
 				return ""
 			}(),
-			h.UpdatedTime().Format("2006-01-02 15:04:05"))
+			h.UpdatedTime().Format("2006-01-02 15:04:05"),
+			h.Schema())
 	}
 	return dummy
 }
@@ -314,7 +391,7 @@ func (h *Header) String() string {
 // UnmarshalFrom will unmarshal Header from a reader.
 func (h *Header) UnmarshalFrom(r io.Reader) error {
 	if h != nil && h.impl != nil {
-		return h.impl.ReadFrom(r)
+		return h.impl.readFrom(r)
 	}
 	return newNilParamError("Header")
 
@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ISOCountry defines a country in the ISO 3166-1 table.
+type ISOCountry struct {
+	Alpha2      string
+	Alpha3      string
+	Numeric     int
+	Name        string
+	ChineseName string
+}
+
+//go:embed iso3166.csv
+var iso3166Data []byte
+
+// isoCountryAliases are the other names of the countries used by the data sources.
+var isoCountryAliases = map[string]string{
+	"香港":                                "HK",
+	"澳门":                                "MO",
+	"台湾":                                "TW",
+	"阿拉伯联合酋长国":                          "AE",
+	"波斯尼亚和黑塞哥维那":                        "BA",
+	"刚果民主共和国":                           "CD",
+	"刚果共和国":                             "CG",
+	"捷克共和国":                             "CZ",
+	"大韩民国":                              "KR",
+	"朝鲜民主主义人民共和国":                       "KP",
+	"俄罗斯联邦":                             "RU",
+	"美利坚合众国":                            "US",
+	"united states":                     "US",
+	"usa":                               "US",
+	"uk":                                "GB",
+	"great britain":                     "GB",
+	"russia":                            "RU",
+	"korea":                             "KR",
+	"republic of korea":                 "KR",
+	"vietnam":                           "VN",
+	"czech republic":                    "CZ",
+	"turkey":                            "TR",
+	"macau":                             "MO",
+	"brunei":                            "BN",
+	"ivory coast":                       "CI",
+	"cape verde":                        "CV",
+	"swaziland":                         "SZ",
+	"macedonia":                         "MK",
+	"moldova, republic of":              "MD",
+	"iran, islamic republic of":         "IR",
+	"syrian arab republic":              "SY",
+	"lao people's democratic republic":  "LA",
+	"tanzania, united republic of":      "TZ",
+	"bolivia, plurinational state of":   "BO",
+	"venezuela, bolivarian republic of": "VE",
+}
+
+// isoCountryDivisions are the regions which have their own ISO 3166-1 codes
+// but are recorded as provinces of China by the data sources.
+var isoCountryDivisions = map[string]string{
+	"香港":  "HK",
+	"澳门":  "MO",
+	"台湾":  "TW",
+	"台湾省": "TW",
+}
+
+type isoTable struct {
+	countries []*ISOCountry
+	byNumeric map[int]*ISOCountry
+	byName    map[string]*ISOCountry
+}
+
+var isoCountries = mustParseISOCountries(iso3166Data)
+
+func mustParseISOCountries(data []byte) *isoTable {
+	table := &isoTable{byNumeric: make(map[int]*ISOCountry), byName: make(map[string]*ISOCountry)}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 5
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			panic(fmt.Sprintf("parse iso 3166 table error, %s", err))
+		}
+		if row == 0 {
+			continue
+		}
+		numeric, err := strconv.Atoi(record[2])
+		if err != nil {
+			panic(fmt.Sprintf("parse iso 3166 numeric code %s error, %s", record[2], err))
+		}
+		country := &ISOCountry{
+			Alpha2: record[0], Alpha3: record[1], Numeric: numeric, Name: record[3], ChineseName: record[4],
+		}
+		table.countries = append(table.countries, country)
+		table.byNumeric[numeric] = country
+		for _, name := range []string{country.Alpha2, country.Alpha3, country.Name, country.ChineseName} {
+			table.byName[strings.ToLower(name)] = country
+		}
+	}
+	for alias, alpha2 := range isoCountryAliases {
+		country, ok := table.byName[strings.ToLower(alpha2)]
+		if !ok {
+			panic(fmt.Sprintf("unknown iso 3166 code %s of alias %s", alpha2, alias))
+		}
+		table.byName[alias] = country
+	}
+	return table
+}
+
+// ISOCountries returns the countries in the ISO 3166-1 table ordered by the alpha-2 code.
+func ISOCountries() []ISOCountry {
+	countries := make([]ISOCountry, 0, len(isoCountries.countries))
+	for _, country := range isoCountries.countries {
+		countries = append(countries, *country)
+	}
+	sort.Slice(countries, func(i, j int) bool { return countries[i].Alpha2 < countries[j].Alpha2 })
+	return countries
+}
+
+// ISOCountryByName returns the country by the English or Chinese name, an alias or a code.
+func ISOCountryByName(name string) (ISOCountry, bool) {
+	if country, ok := isoCountries.byName[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return *country, true
+	}
+	return ISOCountry{}, false
+}
+
+// ISOCountryByNumeric returns the country by the numeric code.
+func ISOCountryByNumeric(numeric int) (ISOCountry, bool) {
+	if country, ok := isoCountries.byNumeric[numeric]; ok {
+		return *country, true
+	}
+	return ISOCountry{}, false
+}
+
+// ISOCountry returns the ISO 3166-1 country of the meta row, matched by the country name or code,
+// the country code column holds the dialing code and is not used.
+func (r *Meta) ISOCountry() (ISOCountry, bool) {
+	if r == nil {
+		return ISOCountry{}, false
+	}
+	if alpha2, ok := isoCountryDivisions[r.province]; ok {
+		if country, _ := ISOCountryByName(r.country); country.Alpha2 == "CN" || r.country == "" {
+			return ISOCountryByName(alpha2)
+		}
+	}
+	if r.country != "" {
+		return ISOCountryByName(r.country)
+	}
+	return ISOCountry{}, false
+}
+
+// ISOCode returns the ISO 3166-1 alpha-2 code of the meta row, empty if not mapped.
+func (r *Meta) ISOCode() string {
+	country, _ := r.ISOCountry()
+	return country.Alpha2
+}
+
+// UnmappedCountries returns the distinct country names of the meta rows
+// which are not mapped to an ISO 3166-1 code.
+func (s *Store) UnmappedCountries() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, meta := range s.MetaTable() {
+		if meta.Country() == "" || meta.ISOCode() != "" {
+			continue
+		}
+		if name := meta.Country(); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,95 @@
+package provider
+
+import "strings"
+
+// localizedSeparator separates the column name and the language tag of a localized column,
+// e.g. "country@en" is the English variant of the country column.
+const localizedSeparator = "@"
+
+// LocalizedNames are the built-in columns which may have language variants.
+var LocalizedNames = []string{
+	ColumnCountry,
+	ColumnProvince,
+	ColumnCity,
+	ColumnDistrict,
+	ColumnISP,
+	ColumnBackboneISP,
+}
+
+// LocalizedColumn returns the name of the language variant of the column.
+func LocalizedColumn(name, lang string) string {
+	return name + localizedSeparator + normalizeLanguage(lang)
+}
+
+// SplitLocalizedColumn returns the column name and the language tag of a localized column,
+// false if the column is not a language variant.
+func SplitLocalizedColumn(column string) (name, lang string, ok bool) {
+	i := strings.LastIndex(column, localizedSeparator)
+	if i <= 0 || i == len(column)-1 {
+		return column, "", false
+	}
+	return column[:i], column[i+1:], true
+}
+
+// LocalizedColumns returns the string columns of the language variants of the built-in names.
+func LocalizedColumns(langs ...string) []Column {
+	columns := make([]Column, 0, len(langs)*len(LocalizedNames))
+	for _, lang := range langs {
+		for _, name := range LocalizedNames {
+			columns = append(columns, Column{Name: LocalizedColumn(name, lang), Type: ColumnTypeString})
+		}
+	}
+	return columns
+}
+
+// normalizeLanguage lowers the language tag and uses "-" as the subtag separator.
+func normalizeLanguage(lang string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+}
+
+// languageFallbacks returns the language tag and its parents, e.g. "zh-hans-cn", "zh-hans", "zh".
+func languageFallbacks(lang string) []string {
+	lang = normalizeLanguage(lang)
+	var fallbacks []string
+	for lang != "" {
+		fallbacks = append(fallbacks, lang)
+		i := strings.LastIndex(lang, "-")
+		if i < 0 {
+			break
+		}
+		lang = lang[:i]
+	}
+	return fallbacks
+}
+
+// WithLocalized returns the meta with the language variant of the named field.
+func (r *Meta) WithLocalized(name, lang, value string) *Meta {
+	return r.WithField(LocalizedColumn(name, lang), value)
+}
+
+// LocalizedName returns the named field in the first language found in the preferences,
+// each language falls back to its parent tags, then to the original value.
+func (r *Meta) LocalizedName(name string, langs ...string) string {
+	for _, lang := range langs {
+		for _, fallback := range languageFallbacks(lang) {
+			if v, _ := r.Get(LocalizedColumn(name, fallback)).(string); v != "" {
+				return v
+			}
+		}
+	}
+	v, _ := r.Get(name).(string)
+	return v
+}
+
+// Localized returns a copy of the meta with the names in the preferred languages,
+// the names without a variant in any of the languages keep the original value.
+func (r *Meta) Localized(langs ...string) *Meta {
+	if r == nil {
+		return r
+	}
+	localized := *r
+	for _, name := range LocalizedNames {
+		localized.WithField(name, r.LocalizedName(name, langs...))
+	}
+	return &localized
+}
@@ -0,0 +1,98 @@
+package provider
+
+import "math"
+
+// Names of the optional location columns.
+const (
+	ColumnLatitude       = "latitude"
+	ColumnLongitude      = "longitude"
+	ColumnAccuracyRadius = "accuracyRadius"
+	ColumnTimeZone       = "timezone"
+	ColumnPostalCode     = "postalCode"
+)
+
+// LocationColumns are the optional location columns of the meta row.
+var LocationColumns = []Column{
+	{Name: ColumnLatitude, Type: ColumnTypeFloat},
+	{Name: ColumnLongitude, Type: ColumnTypeFloat},
+	{Name: ColumnAccuracyRadius, Type: ColumnTypeInt},
+	{Name: ColumnTimeZone, Type: ColumnTypeString},
+	{Name: ColumnPostalCode, Type: ColumnTypeString},
+}
+
+// LocationSchema is the default schema with the location columns.
+var LocationSchema = DefaultSchema.WithColumns(LocationColumns...)
+
+// earthRadius is the mean earth radius in kilometers.
+const earthRadius = 6371.0088
+
+// WithLocation returns the meta with the latitude and longitude in degrees.
+func (r *Meta) WithLocation(latitude, longitude float64) *Meta {
+	return r.WithField(ColumnLatitude, latitude).WithField(ColumnLongitude, longitude)
+}
+
+// WithAccuracyRadius returns the meta with the accuracy radius in kilometers.
+func (r *Meta) WithAccuracyRadius(radius int) *Meta {
+	return r.WithField(ColumnAccuracyRadius, radius)
+}
+
+// WithTimeZone returns the meta with the IANA time zone name.
+func (r *Meta) WithTimeZone(timeZone string) *Meta {
+	return r.WithField(ColumnTimeZone, timeZone)
+}
+
+// WithPostalCode returns the meta with the postal code.
+func (r *Meta) WithPostalCode(postalCode string) *Meta {
+	return r.WithField(ColumnPostalCode, postalCode)
+}
+
+// Latitude returns the latitude in degrees in the meta row information.
+func (r *Meta) Latitude() float64 {
+	v, _ := r.Get(ColumnLatitude).(float64)
+	return v
+}
+
+// Longitude returns the longitude in degrees in the meta row information.
+func (r *Meta) Longitude() float64 {
+	v, _ := r.Get(ColumnLongitude).(float64)
+	return v
+}
+
+// HasLocation returns true if the meta row has a latitude or a longitude.
+func (r *Meta) HasLocation() bool {
+	return r.Latitude() != 0 || r.Longitude() != 0
+}
+
+// AccuracyRadius returns the accuracy radius in kilometers in the meta row information.
+func (r *Meta) AccuracyRadius() int {
+	v, _ := r.Get(ColumnAccuracyRadius).(int)
+	return v
+}
+
+// TimeZone returns the IANA time zone name in the meta row information.
+func (r *Meta) TimeZone() string {
+	v, _ := r.Get(ColumnTimeZone).(string)
+	return v
+}
+
+// PostalCode returns the postal code in the meta row information.
+func (r *Meta) PostalCode() string {
+	v, _ := r.Get(ColumnPostalCode).(string)
+	return v
+}
+
+// DistanceTo returns the great-circle distance in kilometers to the other meta,
+// false if either of them has no location.
+func (r *Meta) DistanceTo(o *Meta) (float64, bool) {
+	if !r.HasLocation() || !o.HasLocation() {
+		return 0, false
+	}
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	lat1, lat2 := toRadians(r.Latitude()), toRadians(o.Latitude())
+	dLat := lat2 - lat1
+	dLon := toRadians(o.Longitude() - r.Longitude())
+	// haversine formula
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h))), true
+}
@@ -2,8 +2,12 @@ package provider
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Meta defines the meta row information.
@@ -16,6 +20,17 @@ type Meta struct {
 	backboneISP string
 	countryCode int
 	areaCode    int
+	// schema declares the columns of the meta row, nil means DefaultSchema
+	schema *Schema
+	// extra holds the values of the columns which are not built in
+	extra map[string]interface{}
+}
+
+// Field defines a named meta value.
+type Field struct {
+	Name  string
+	Type  ColumnType
+	Value interface{}
 }
 
 // NewMeta returns a new meta.
@@ -92,6 +107,140 @@ func (r *Meta) WithAreaCode(a int) *Meta {
 	return r
 }
 
+// WithSchema returns the meta with the schema used to marshal and unmarshal the row.
+func (r *Meta) WithSchema(schema *Schema) *Meta {
+	if r != nil {
+		r.schema = schema
+	}
+	return r
+}
+
+// WithField returns the meta with the named field, built-in fields are set by name as well.
+func (r *Meta) WithField(name string, value interface{}) *Meta {
+	if r == nil {
+		return r
+	}
+	switch name {
+	case ColumnCountry:
+		r.country, _ = value.(string)
+	case ColumnProvince:
+		r.province, _ = value.(string)
+	case ColumnCity:
+		r.city, _ = value.(string)
+	case ColumnDistrict:
+		r.district, _ = value.(string)
+	case ColumnISP:
+		r.isp, _ = value.(string)
+	case ColumnBackboneISP:
+		r.backboneISP, _ = value.(string)
+	case ColumnCountryCode:
+		r.countryCode, _ = value.(int)
+	case ColumnAreaCode:
+		r.areaCode, _ = value.(int)
+	default:
+		if r.extra == nil {
+			r.extra = make(map[string]interface{})
+		}
+		r.extra[name] = value
+	}
+	return r
+}
+
+// Schema returns the schema of the meta row.
+func (r *Meta) Schema() *Schema {
+	if r != nil && r.schema != nil {
+		return r.schema
+	}
+	return DefaultSchema
+}
+
+// Get returns the value of the named field, nil if the field is not set.
+func (r *Meta) Get(name string) interface{} {
+	if r == nil {
+		return nil
+	}
+	switch name {
+	case ColumnCountry:
+		return r.country
+	case ColumnProvince:
+		return r.province
+	case ColumnCity:
+		return r.city
+	case ColumnDistrict:
+		return r.district
+	case ColumnISP:
+		return r.isp
+	case ColumnBackboneISP:
+		return r.backboneISP
+	case ColumnCountryCode:
+		return r.countryCode
+	case ColumnAreaCode:
+		return r.areaCode
+	default:
+		if v, ok := r.extra[name]; ok {
+			return v
+		}
+		// declared columns without value report the zero value of their type
+		if i := r.Schema().Index(name); i >= 0 {
+			return zeroValue(r.Schema().Column(i).Type)
+		}
+		return nil
+	}
+}
+
+// Fields returns the declared fields in schema order, then the undeclared extra fields by name.
+func (r *Meta) Fields() []Field {
+	schema := r.Schema()
+	fields := make([]Field, 0, schema.Len()+len(r.extraNames()))
+	for _, column := range schema.Columns() {
+		value := r.Get(column.Name)
+		if value == nil {
+			value = zeroValue(column.Type)
+		}
+		fields = append(fields, Field{Name: column.Name, Type: column.Type, Value: value})
+	}
+	for _, name := range r.extraNames() {
+		if schema.Index(name) < 0 {
+			fields = append(fields, Field{Name: name, Type: valueType(r.extra[name]), Value: r.extra[name]})
+		}
+	}
+	return fields
+}
+
+func (r *Meta) extraNames() []string {
+	if r == nil || len(r.extra) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(r.extra))
+	for name := range r.extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func zeroValue(t ColumnType) interface{} {
+	switch t {
+	case ColumnTypeInt:
+		return 0
+	case ColumnTypeFloat:
+		return float64(0)
+	default:
+		return ""
+	}
+}
+
+func valueType(v interface{}) ColumnType {
+	switch v.(type) {
+	case int:
+		return ColumnTypeInt
+	case float64:
+		return ColumnTypeFloat
+	default:
+		return ColumnTypeString
+	}
+}
+
 // Country returns the Country in the meta row information.
 func (r *Meta) Country() string {
 	if r != nil {
@@ -170,21 +319,116 @@ func (r *Meta) IsEmpty() bool {
 		r.ISP() == "" &&
 		r.BackboneISP() == "" &&
 		r.CountryCode() == 0 &&
-		r.AreaCode() == 0
+		r.AreaCode() == 0 &&
+		r.extraIsEmpty()
+}
+
+func (r *Meta) extraIsEmpty() bool {
+	if r != nil {
+		for _, v := range r.extra {
+			if v != nil && v != zeroValue(valueType(v)) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 func (r *Meta) String() string {
 	if r != nil {
-		return fmt.Sprintf("country:%q province:%q city:%q district:%q "+
+		dummy := fmt.Sprintf("country:%q province:%q city:%q district:%q "+
 			"ISP:%q backboneISP:%q countryCode:%d areaCode:%d",
 			r.Country(), r.Province(), r.City(), r.District(),
 			r.ISP(), r.BackboneISP(), r.CountryCode(), r.AreaCode())
+		for _, name := range r.extraNames() {
+			dummy += fmt.Sprintf(" %s:%v", name, r.extra[name])
+		}
+		return dummy
 	}
 	return ""
 }
 
-// UnmarshalString will fill the details into meta row.
+// parseValue parses the column value by the column type.
+func parseValue(t ColumnType, s string) (interface{}, error) {
+	switch t {
+	case ColumnTypeInt:
+		if s == "" {
+			return 0, nil
+		}
+		return strconv.Atoi(s)
+	case ColumnTypeFloat:
+		if s == "" {
+			return float64(0), nil
+		}
+		return strconv.ParseFloat(s, 64)
+	default:
+		return s, nil
+	}
+}
+
+// formatValue formats the column value by the column type.
+func formatValue(t ColumnType, v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return formatValue(t, zeroValue(t))
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// UnmarshalString will fill the details into meta row by the columns of the meta schema.
 func (r *Meta) UnmarshalString(line string) error {
+	if r != nil {
+		return r.unmarshalItems(strings.Split(strings.TrimSuffix(line, "\n"), "\t"), false)
+	}
+	return nil
+}
+
+// MetaError describes the column of the meta row which can not be decoded.
+type MetaError struct {
+	// Row is the index of the row in the meta table, -1 if unknown
+	Row int
+	// Column is the index of the column in the schema
+	Column int
+	// Name is the name of the column, empty if not declared
+	Name string
+	Err  error
+}
+
+func (e *MetaError) Error() string {
+	var dummy string
+	if e.Row >= 0 {
+		dummy = fmt.Sprintf("row[%d] ", e.Row)
+	}
+	dummy += fmt.Sprintf("column[%d]", e.Column)
+	if e.Name != "" {
+		dummy += " " + e.Name
+	}
+	return fmt.Sprintf("%s: %s", dummy, e.Err)
+}
+
+func (e *MetaError) Unwrap() error {
+	return e.Err
+}
+
+// metaColumnRanges are the valid ranges of the numeric columns in strict mode.
+var metaColumnRanges = map[string][2]float64{
+	ColumnCountryCode:    {0, 999},
+	ColumnAreaCode:       {0, 999999},
+	ColumnLatitude:       {-90, 90},
+	ColumnLongitude:      {-180, 180},
+	ColumnAccuracyRadius: {0, 20038},
+	ColumnASN:            {0, 4294967295},
+}
+
+// unmarshalItems fills the unescaped items into meta row by the columns of the meta schema.
+func (r *Meta) unmarshalItems(items []string, strict bool) error {
 	toInt := func(s string) (int, error) {
 		if s == "" {
 			return 0, nil
@@ -193,34 +437,82 @@ func (r *Meta) UnmarshalString(line string) error {
 	}
 
 	var e error
-	if r != nil {
-		for i, item := range strings.Split(
-			strings.TrimSuffix(line, "\n"), "\t") {
-			switch i {
-			case 0:
-				r.country = item
-			case 1:
-				r.province = item
-			case 2:
-				r.city = item
-			case 3:
-				r.district = item
-			case 4:
-				r.isp = item
-			case 5:
-				r.backboneISP = item
-			case 6:
-				if r.countryCode, e = toInt(item); e != nil {
-					break
-				}
-			case 7:
-				if r.areaCode, e = toInt(item); e != nil {
-					break
+	schema := r.Schema()
+	newError := func(i int, err error) error {
+		return &MetaError{Row: -1, Column: i, Name: schema.Column(i).Name, Err: err}
+	}
+	if strict && len(items) < schema.Len() {
+		return newError(len(items), fmt.Errorf("missing column, %d of %d columns", len(items), schema.Len()))
+	}
+	if strict && len(items) > schema.Len() {
+		return newError(schema.Len(), fmt.Errorf("unexpected column, %d of %d columns", len(items), schema.Len()))
+	}
+	for i, item := range items {
+		if i >= schema.Len() {
+			break
+		}
+		if strict {
+			if err := checkItem(schema.Column(i), item); err != nil {
+				return newError(i, err)
+			}
+		}
+		switch column := schema.Column(i); column.Name {
+		case ColumnCountry:
+			r.country = item
+		case ColumnProvince:
+			r.province = item
+		case ColumnCity:
+			r.city = item
+		case ColumnDistrict:
+			r.district = item
+		case ColumnISP:
+			r.isp = item
+		case ColumnBackboneISP:
+			r.backboneISP = item
+		case ColumnCountryCode:
+			r.countryCode, e = toInt(item)
+		case ColumnAreaCode:
+			r.areaCode, e = toInt(item)
+		default:
+			var v interface{}
+			if v, e = parseValue(column.Type, item); e == nil {
+				if r.extra == nil {
+					r.extra = make(map[string]interface{})
 				}
+				r.extra[column.Name] = v
 			}
 		}
+		// the first malformed column fails the row, a later valid one must not clear the error
+		if e != nil {
+			return newError(i, fmt.Errorf("invalid %s %q", schema.Column(i).Type, item))
+		}
+	}
+	return nil
+}
+
+// checkItem checks the UTF-8 encoding of the item and the range of a numeric column in strict mode.
+func checkItem(column Column, item string) error {
+	if !utf8.ValidString(item) {
+		return fmt.Errorf("invalid UTF-8 %q", item)
+	}
+	v, err := parseValue(column.Type, item)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", column.Type, item)
+	}
+	var number float64
+	switch value := v.(type) {
+	case int:
+		number = float64(value)
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("invalid %s %q", column.Type, item)
+		}
+		number = value
 	}
-	return e
+	if bounds, ok := metaColumnRanges[column.Name]; ok && (number < bounds[0] || number > bounds[1]) {
+		return fmt.Errorf("%s out of range [%v, %v]", item, bounds[0], bounds[1])
+	}
+	return nil
 }
 
 // Unmarshal a bytes array and fill the details into meta row.
@@ -228,15 +520,22 @@ func (r *Meta) Unmarshal(buffer []byte) error {
 	return r.UnmarshalString(string(buffer[:]))
 }
 
-// MarshalString will serialize the data entity to a string.
+// MarshalString will serialize the data entity to a string by the columns of the meta schema.
 func (r *Meta) MarshalString() (string, error) {
-	var line string
-	if r != nil {
-		line = fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d",
-			r.Country(), r.Province(), r.City(), r.District(),
-			r.ISP(), r.BackboneISP(), r.CountryCode(), r.AreaCode())
+	return strings.Join(r.marshalItems(), "\t"), nil
+}
+
+// marshalItems formats the values of the meta row by the columns of the meta schema.
+func (r *Meta) marshalItems() []string {
+	if r == nil {
+		return nil
 	}
-	return line, nil
+	schema := r.Schema()
+	items := make([]string, schema.Len())
+	for i, column := range schema.Columns() {
+		items[i] = formatValue(column.Type, r.Get(column.Name))
+	}
+	return items
 }
 
 // Marshal will serialize the data entity to a string.
@@ -244,3 +543,123 @@ func (r *Meta) Marshal() ([]byte, error) {
 	line, err := r.MarshalString()
 	return []byte(line), err
 }
+
+// escapeItem escapes the backslash and the row separators of the item.
+func escapeItem(item string) string {
+	if !strings.ContainsAny(item, "\\\t\n\r") {
+		return item
+	}
+	var builder strings.Builder
+	builder.Grow(len(item) + 8)
+	for i := 0; i < len(item); i++ {
+		switch c := item[i]; c {
+		case '\\':
+			builder.WriteString(`\\`)
+		case '\t':
+			builder.WriteString(`\t`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\r':
+			builder.WriteString(`\r`)
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+// unescapeItem reverses escapeItem.
+func unescapeItem(item string) (string, error) {
+	if !strings.Contains(item, `\`) {
+		return item, nil
+	}
+	var builder strings.Builder
+	builder.Grow(len(item))
+	for i := 0; i < len(item); i++ {
+		c := item[i]
+		if c != '\\' {
+			builder.WriteByte(c)
+			continue
+		}
+		if i++; i == len(item) {
+			return "", fmt.Errorf("unterminated escape in %q", item)
+		}
+		switch item[i] {
+		case '\\':
+			builder.WriteByte('\\')
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		default:
+			return "", fmt.Errorf("invalid escape \\%c in %q", item[i], item)
+		}
+	}
+	return builder.String(), nil
+}
+
+// MetaMarshaler defines a marshaler for the meta row of the data version.
+type MetaMarshaler struct {
+	DataVersion DataVersion
+}
+
+// MarshalString will serialize the meta to a row without the line separator,
+// the separators in values are escaped since DataVersionEscaped.
+func (m *MetaMarshaler) MarshalString(meta *Meta) (string, error) {
+	if m == nil {
+		return "", newNilParamError("MetaMarshaler")
+	}
+	items := meta.marshalItems()
+	for i, item := range items {
+		if m.DataVersion >= DataVersionEscaped {
+			items[i] = escapeItem(item)
+		} else if strings.ContainsAny(item, "\t\n") {
+			return "", fmt.Errorf("%s %q contains a separator, which needs data version %d",
+				meta.Schema().Column(i).Name, item, DataVersionEscaped)
+		}
+	}
+	return strings.Join(items, "\t"), nil
+}
+
+// MarshalTo will marshal the meta row with the line separator to a writer.
+func (m *MetaMarshaler) MarshalTo(meta *Meta, writer io.Writer) (int, error) {
+	if writer == nil {
+		return 0, newNilParamError("Writer")
+	}
+	line, err := m.MarshalString(meta)
+	if err != nil {
+		return 0, err
+	}
+	return io.WriteString(writer, line+"\n")
+}
+
+// MetaUnmarshaler defines a unmarshaler for the meta row of the data version.
+type MetaUnmarshaler struct {
+	DataVersion DataVersion
+	// Strict rejects the rows with a wrong column count, invalid UTF-8
+	// or numbers out of the column range
+	Strict bool
+}
+
+// UnmarshalString will fill the row into the meta by the columns of the meta schema,
+// the separators in values are unescaped since DataVersionEscaped.
+func (u *MetaUnmarshaler) UnmarshalString(line string, meta *Meta) error {
+	if u == nil {
+		return newNilParamError("MetaUnmarshaler")
+	}
+	if meta == nil {
+		return newNilParamError("Meta")
+	}
+	items := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+	if u.DataVersion >= DataVersionEscaped {
+		for i, item := range items {
+			var err error
+			if items[i], err = unescapeItem(item); err != nil {
+				return &MetaError{Row: -1, Column: i, Name: meta.Schema().Column(i).Name, Err: err}
+			}
+		}
+	}
+	return meta.unmarshalItems(items, u.Strict)
+}
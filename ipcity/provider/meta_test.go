@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func FuzzMetaRowRoundTrip(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"电信", ""}, {"a\tb", "c\nd"}, {`\t`, "\\"}, {"\r\n", "\xff\xfe"}, {"", "\t\t\n"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	schema := DefaultSchema.WithColumns(Column{Name: "note", Type: ColumnTypeString})
+	f.Fuzz(func(t *testing.T, isp, note string) {
+		meta := NewMeta().WithSchema(schema).WithISP(isp).WithField("note", note).WithCountryCode(86)
+		line, err := (&MetaMarshaler{DataVersion: DataVersionLatest}).MarshalString(meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.ContainsAny(line, "\n\r") || strings.Count(line, "\t") != schema.Len()-1 {
+			t.Fatalf("unescaped separator in %q", line)
+		}
+		decoded := NewMeta().WithSchema(schema)
+		if err = (&MetaUnmarshaler{DataVersion: DataVersionLatest}).
+			UnmarshalString(line+"\n", decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.ISP() != isp || decoded.Get("note") != note || decoded.CountryCode() != 86 {
+			t.Errorf("unexpected meta %s, want %s", decoded, meta)
+		}
+	})
+}
+
+func FuzzStoreRoundTrip(f *testing.F) {
+	f.Add("中国", "电信\n联通", "广\t州")
+	f.Add(`\`, "\r", "")
+	f.Fuzz(func(t *testing.T, country, isp, city string) {
+		data, err := newTestStore(DataModeIPv4,
+			[]uint64{0x00000000, 0x01000000, 0x02000000},
+			[]*Meta{
+				NewMeta(),
+				NewMeta().WithCountry(country).WithISP(isp).WithCity(city),
+				NewMeta().WithCountry("美国"),
+			}).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore()
+		if err = store.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		meta := store.SearchAddr(netip.MustParseAddr("1.2.3.4"))
+		if meta.Country() != country || meta.ISP() != isp || meta.City() != city {
+			t.Errorf("unexpected meta %s", meta)
+		}
+		if meta = store.SearchAddr(netip.MustParseAddr("2.2.3.4")); meta.Country() != "美国" {
+			t.Errorf("unexpected meta %s", meta)
+		}
+		if encoded, err := store.MarshalBinary(); err != nil || !bytes.Equal(encoded, data) {
+			t.Errorf("unexpected encoded store, %v", err)
+		}
+	})
+}
+
+func TestMetaRowLegacyVersion(t *testing.T) {
+	meta := NewMeta().WithISP("a\tb")
+	if _, err := (&MetaMarshaler{DataVersion: DataVersionSchema}).MarshalString(meta); err == nil {
+		t.Errorf("expected separator error for data version %d", DataVersionSchema)
+	}
+	legacy := NewMeta()
+	if err := (&MetaUnmarshaler{DataVersion: DataVersionSchema}).
+		UnmarshalString("中国\t\t\t\t\\\\n", legacy); err != nil || legacy.ISP() != `\\n` {
+		t.Errorf("unexpected legacy meta %s, %v", legacy, err)
+	}
+}
+
+func TestStoreStrict(t *testing.T) {
+	for _, c := range []struct {
+		meta   *Meta
+		column int
+	}{
+		{NewMeta().WithCountry("美国").WithCountryCode(1000), 6},
+		{NewMeta().WithCountry("美\xff国"), 0},
+	} {
+		data, err := newTestStore(DataModeIPv4,
+			[]uint64{0x00000000, 0x01000000, 0x02000000},
+			[]*Meta{NewMeta(), NewMeta().WithCountry("中国"), c.meta}).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = NewStore().UnmarshalBinary(data); err != nil {
+			t.Errorf("unexpected lenient error, %s", err)
+		}
+		err = (&StoreUnmarshaler{Strict: true}).UnmarshalFrom(bytes.NewReader(data), NewStore())
+		var metaError *MetaError
+		if !errors.As(err, &metaError) || metaError.Row != 2 || metaError.Column != c.column {
+			t.Errorf("unexpected strict error of %s, %v", c.meta, err)
+		}
+	}
+
+	unmarshaler := &MetaUnmarshaler{DataVersion: DataVersionLatest, Strict: true}
+	for _, line := range []string{"中国\t\t\t\t\t\t86", "中国\t\t\t\t\t\t86\t0\textra", "中国\t\t\t\t\t\tx\t0"} {
+		if err := unmarshaler.UnmarshalString(line, NewMeta()); err == nil {
+			t.Errorf("expected strict error of %q", line)
+		}
+	}
+	// the first numeric error is reported in lenient mode as well
+	err := NewMeta().UnmarshalString("中国\t\t\t\t\t\tx\t0")
+	var metaError *MetaError
+	if !errors.As(err, &metaError) || metaError.Name != ColumnCountryCode {
+		t.Errorf("unexpected lenient error, %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"encoding/binary"
+	"math/big"
+	"net/netip"
+	"sort"
+)
+
+// Range defines an inclusive address range.
+type Range struct {
+	First netip.Addr
+	Last  netip.Addr
+}
+
+// IsValid returns true if the range holds a pair of addresses in the same family.
+func (r Range) IsValid() bool {
+	return r.First.IsValid() && r.Last.IsValid() && r.First.BitLen() == r.Last.BitLen() &&
+		!r.Last.Less(r.First)
+}
+
+// Contains returns true if the address is in the range.
+func (r Range) Contains(addr netip.Addr) bool {
+	return r.IsValid() && addr.BitLen() == r.First.BitLen() &&
+		!addr.Less(r.First) && !r.Last.Less(addr)
+}
+
+// span returns Last-First as a 128-bit number.
+func (r Range) span() (hi, lo uint64) {
+	first, last := r.First.As16(), r.Last.As16()
+	fhi, flo := binary.BigEndian.Uint64(first[:8]), binary.BigEndian.Uint64(first[8:])
+	lhi, llo := binary.BigEndian.Uint64(last[:8]), binary.BigEndian.Uint64(last[8:])
+	lo = llo - flo
+	hi = lhi - fhi
+	if llo < flo {
+		hi--
+	}
+	return hi, lo
+}
+
+// Size returns the count of the addresses in the range, 0 if the range is invalid.
+func (r Range) Size() *big.Int {
+	if !r.IsValid() {
+		return new(big.Int)
+	}
+	hi, lo := r.span()
+	size := new(big.Int).SetUint64(hi)
+	size.Lsh(size, 64).Or(size, new(big.Int).SetUint64(lo))
+	return size.Add(size, big.NewInt(1))
+}
+
+// Narrower returns true if the range covers fewer addresses than the other one.
+func (r Range) Narrower(o Range) bool {
+	rhi, rlo := r.span()
+	ohi, olo := o.span()
+	return rhi < ohi || (rhi == ohi && rlo < olo)
+}
+
+// Prefix returns the prefix covering exactly the range, false if the range is not a prefix.
+func (r Range) Prefix() (netip.Prefix, bool) {
+	if !r.IsValid() {
+		return netip.Prefix{}, false
+	}
+	for bits := r.First.BitLen(); bits >= 0; bits-- {
+		p := netip.PrefixFrom(r.First, bits)
+		if p.Masked().Addr() != r.First {
+			break
+		}
+		if PrefixRange(p).Last == r.Last {
+			return p, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+func (r Range) String() string {
+	if !r.IsValid() {
+		return ""
+	}
+	return r.First.String() + "-" + r.Last.String()
+}
+
+// ipIndexAddr returns the first or last address covered by the ip index in the store.
+func (s *Store) ipIndexAddr(ipIndex uint64, last bool) netip.Addr {
+	switch s.Header().AddrBitLen() {
+	case 32:
+		var v uint32
+		if s.Header().Version() == DataVersion(2) {
+			v = uint32(ipIndex << 8)
+			if last {
+				v |= 0xFF
+			}
+		} else {
+			v = uint32(ipIndex)
+		}
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], v)
+		return netip.AddrFrom4(b)
+	case 128:
+		var b [16]byte
+		binary.BigEndian.PutUint64(b[:8], ipIndex)
+		if last {
+			binary.BigEndian.PutUint64(b[8:], ^uint64(0))
+		}
+		return netip.AddrFrom16(b)
+	default:
+		return netip.Addr{}
+	}
+}
+
+// EntityRange returns the address range of the pointed index entity.
+func (s *Store) EntityRange(i int) Range {
+	entity := s.Entity(i)
+	if entity == nil {
+		return Range{}
+	}
+	r := Range{First: s.ipIndexAddr(entity.IPIndex(), false)}
+	if next := s.Entity(i + 1); next != nil {
+		r.Last = s.ipIndexAddr(next.IPIndex()-1, true)
+	} else {
+		r.Last = s.ipIndexAddr(s.maxIPIndex(), true)
+	}
+	return r
+}
+
+// maxIPIndex returns the largest ip index of the store mode.
+func (s *Store) maxIPIndex() uint64 {
+	switch s.Header().AddrBitLen() {
+	case 32:
+		if s.Header().Version() == DataVersion(2) {
+			return 0xFFFFFF
+		}
+		return 0xFFFFFFFF
+	default:
+		return ^uint64(0)
+	}
+}
+
+// SearchRange returns the meta queryed from the store and the range it belongs to.
+func (s *Store) SearchRange(addr netip.Addr) (*Meta, Range) {
+	if ipIndex, ok := s.ipIndex(addr); ok {
+		i := s.searchEntity(ipIndex)
+		if entity := s.Entity(i); entity != nil {
+			return s.Meta(int(entity.MetaRowIndex())), s.EntityRange(i)
+		}
+	}
+	return nil, Range{}
+}
+
+// PrefixRange returns the address range covered by the prefix.
+func PrefixRange(p netip.Prefix) Range {
+	if !p.IsValid() {
+		return Range{}
+	}
+	p = p.Masked()
+	first := p.Addr()
+	b := first.As16()
+	offset := 128 - first.BitLen()
+	for i := offset + p.Bits(); i < 128; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	last := netip.AddrFrom16(b)
+	if first.Is4() {
+		last = last.Unmap()
+	}
+	return Range{First: first, Last: last}
+}
+
+// Prefixes returns the fewest prefixes covering exactly the range.
+func (r Range) Prefixes() []netip.Prefix {
+	if !r.IsValid() {
+		return nil
+	}
+	var prefixes []netip.Prefix
+	for first := r.First; ; {
+		// the shortest prefix starting at first and ending before last
+		var p netip.Prefix
+		for bits := 0; bits <= first.BitLen(); bits++ {
+			candidate := netip.PrefixFrom(first, bits)
+			if candidate.Masked().Addr() == first && !r.Last.Less(PrefixRange(candidate).Last) {
+				p = candidate
+				break
+			}
+		}
+		prefixes = append(prefixes, p)
+		last := PrefixRange(p).Last
+		if last == r.Last {
+			return prefixes
+		}
+		first = last.Next()
+	}
+}
+
+// MergeRanges returns the ranges sorted with the overlapping and adjacent ones merged,
+// the invalid ranges are dropped.
+func MergeRanges(ranges []Range) []Range {
+	sorted := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r.IsValid() {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].First.BitLen() != sorted[j].First.BitLen() {
+			return sorted[i].First.BitLen() < sorted[j].First.BitLen()
+		}
+		return sorted[i].First.Less(sorted[j].First)
+	})
+	merged := sorted[:0]
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && merged[n-1].First.BitLen() == r.First.BitLen() {
+			last := &merged[n-1]
+			next := last.Last.Next()
+			if !next.IsValid() || !next.Less(r.First) {
+				if last.Last.Less(r.Last) {
+					last.Last = r.Last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// RangePrefixes returns the fewest prefixes covering the ranges.
+func RangePrefixes(ranges []Range) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, r := range MergeRanges(ranges) {
+		prefixes = append(prefixes, r.Prefixes()...)
+	}
+	return prefixes
+}
+
+// Ranges returns the merged address ranges of the non-empty meta rows matching the filter.
+func (s *Store) Ranges(filter *Filter) []Range {
+	matched := make([]bool, s.MetaRowCount())
+	for i, meta := range s.MetaTable() {
+		matched[i] = !meta.IsEmpty() && filter.Match(meta)
+	}
+	var ranges []Range
+	for i, entity := range s.EntityList() {
+		if index := int(entity.MetaRowIndex()); index < len(matched) && matched[index] {
+			ranges = append(ranges, s.EntityRange(i))
+		}
+	}
+	return MergeRanges(ranges)
+}
@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ColumnType is the value type of a meta column.
+type ColumnType byte
+
+const (
+	// ColumnTypeUnknown is the unknown column type.
+	ColumnTypeUnknown = ColumnType(0)
+	// ColumnTypeString means the column holds a string.
+	ColumnTypeString = ColumnType(1)
+	// ColumnTypeInt means the column holds an int.
+	ColumnTypeInt = ColumnType(2)
+	// ColumnTypeFloat means the column holds a float64.
+	ColumnTypeFloat = ColumnType(3)
+)
+
+// ColumnTypeName is a mapping for column type name.
+var ColumnTypeName = map[ColumnType]string{
+	ColumnTypeUnknown: "unknown",
+	ColumnTypeString:  "string",
+	ColumnTypeInt:     "int",
+	ColumnTypeFloat:   "float",
+}
+
+func (t ColumnType) String() string {
+	if name, ok := ColumnTypeName[t]; ok {
+		return name
+	}
+	return ColumnTypeName[ColumnTypeUnknown]
+}
+
+// Names of the built-in meta columns.
+const (
+	ColumnCountry     = "country"
+	ColumnProvince    = "province"
+	ColumnCity        = "city"
+	ColumnDistrict    = "district"
+	ColumnISP         = "isp"
+	ColumnBackboneISP = "backboneISP"
+	ColumnCountryCode = "countryCode"
+	ColumnAreaCode    = "areaCode"
+)
+
+// Column defines a named and typed meta column.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Schema defines the ordered meta columns of a data file.
+type Schema struct {
+	columns []Column
+	index   map[string]int
+}
+
+// NewSchema returns a new schema with the columns.
+func NewSchema(columns ...Column) *Schema {
+	s := &Schema{columns: columns, index: make(map[string]int, len(columns))}
+	for i, column := range columns {
+		s.index[column.Name] = i
+	}
+	return s
+}
+
+// DefaultSchema is the fixed meta layout of the data version before 4.
+var DefaultSchema = NewSchema(
+	Column{Name: ColumnCountry, Type: ColumnTypeString},
+	Column{Name: ColumnProvince, Type: ColumnTypeString},
+	Column{Name: ColumnCity, Type: ColumnTypeString},
+	Column{Name: ColumnDistrict, Type: ColumnTypeString},
+	Column{Name: ColumnISP, Type: ColumnTypeString},
+	Column{Name: ColumnBackboneISP, Type: ColumnTypeString},
+	Column{Name: ColumnCountryCode, Type: ColumnTypeInt},
+	Column{Name: ColumnAreaCode, Type: ColumnTypeInt},
+)
+
+// WithColumns returns a new schema with the columns appended.
+func (s *Schema) WithColumns(columns ...Column) *Schema {
+	return NewSchema(append(s.Columns(), columns...)...)
+}
+
+// Columns returns a copy of the columns of the schema.
+func (s *Schema) Columns() []Column {
+	if s != nil {
+		return append([]Column(nil), s.columns...)
+	}
+	return nil
+}
+
+// Len returns the count of the columns.
+func (s *Schema) Len() int {
+	if s != nil {
+		return len(s.columns)
+	}
+	return 0
+}
+
+// Column returns the pointed index column.
+func (s *Schema) Column(i int) Column {
+	if s != nil && i >= 0 && i < len(s.columns) {
+		return s.columns[i]
+	}
+	return Column{}
+}
+
+// Index returns the index of the named column, -1 if not declared.
+func (s *Schema) Index(name string) int {
+	if s != nil {
+		if i, ok := s.index[name]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Schema) String() string {
+	var dummy string
+	for i, column := range s.Columns() {
+		if i > 0 {
+			dummy += " "
+		}
+		dummy += fmt.Sprintf("%s:%s", column.Name, column.Type)
+	}
+	return "{" + dummy + "}"
+}
+
+// readFrom reads the schema block: a uint16 column count, then a type byte,
+// a name length byte and the name of each column.
+func (s *Schema) readFrom(r io.Reader) (int64, error) {
+	buffer := make([]byte, 2)
+	n, err := io.ReadFull(r, buffer)
+	if err != nil {
+		return int64(n), fmt.Errorf("read schema column count error, %s", err)
+	}
+	count := int(binary.BigEndian.Uint16(buffer))
+	columns := make([]Column, 0, count)
+	for i := 0; i < count; i++ {
+		m, err := io.ReadFull(r, buffer)
+		n += m
+		if err != nil {
+			return int64(n), fmt.Errorf("read schema column[%d] error, %s", i, err)
+		}
+		name := make([]byte, buffer[1])
+		m, err = io.ReadFull(r, name)
+		n += m
+		if err != nil {
+			return int64(n), fmt.Errorf("read schema column[%d] name error, %s", i, err)
+		}
+		columns = append(columns, Column{Name: string(name), Type: ColumnType(buffer[0])})
+	}
+	*s = *NewSchema(columns...)
+	return int64(n), nil
+}
+
+// writeTo writes the schema block.
+func (s *Schema) writeTo(w io.Writer) error {
+	if s.Len() > 0xFFFF {
+		return fmt.Errorf("too many schema columns %d", s.Len())
+	}
+	if err := binary.Write(w, binary.BigEndian, uint16(s.Len())); err != nil {
+		return err
+	}
+	for _, column := range s.Columns() {
+		if len(column.Name) == 0 || len(column.Name) > 0xFF {
+			return fmt.Errorf("invalid schema column name %q", column.Name)
+		}
+		if _, err := w.Write(append([]byte{byte(column.Type), byte(len(column.Name))}, column.Name...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
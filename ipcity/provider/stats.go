@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"math/big"
+	"sort"
+)
+
+// NameCount defines the ranges and addresses of a country, a province or an ISP.
+type NameCount struct {
+	Name      string   `json:"name"`
+	Ranges    int      `json:"ranges"`
+	Addresses *big.Int `json:"addresses"`
+}
+
+// SizeCount defines the count of the ranges of a size class.
+type SizeCount struct {
+	// Bits is the length of the largest prefix which the ranges are not smaller than,
+	// a range of the class holds at least a /Bits and less than a /(Bits-1) of addresses
+	Bits   int `json:"bits"`
+	Ranges int `json:"ranges"`
+}
+
+// StoreStats defines the coverage and composition of a store.
+type StoreStats struct {
+	Mode string `json:"mode"`
+	// Space is the count of the addresses of the store family
+	Space *big.Int `json:"space"`
+	// Ranges and Covered are the count of the entity ranges of a non-empty meta and their addresses
+	Ranges  int      `json:"ranges"`
+	Covered *big.Int `json:"covered"`
+	// GapRanges and Gaps are the count of the ranges of the empty meta or of no entity and their addresses
+	GapRanges int      `json:"gapRanges"`
+	Gaps      *big.Int `json:"gaps"`
+	// Countries, Provinces and ISPs count the covered ranges by name, the most addresses first
+	Countries []NameCount `json:"countries"`
+	Provinces []NameCount `json:"provinces"`
+	ISPs      []NameCount `json:"isps"`
+	// Unreferenced are the indexes of the non-empty meta rows which no entity points to
+	Unreferenced []int `json:"unreferenced"`
+	// Sizes are the covered ranges by size class, the largest class first
+	Sizes []SizeCount `json:"sizes"`
+}
+
+// Stats returns the coverage and composition statistics of the store.
+func (s *Store) Stats() *StoreStats {
+	bitLen := s.Header().AddrBitLen()
+	stats := &StoreStats{
+		Mode:         s.Header().ModeName(),
+		Space:        big.NewInt(0),
+		Covered:      big.NewInt(0),
+		Gaps:         big.NewInt(0),
+		Unreferenced: []int{},
+		Sizes:        []SizeCount{},
+	}
+	if bitLen == 0 {
+		return stats
+	}
+	stats.Space.Lsh(big.NewInt(1), uint(bitLen))
+
+	countries := make(map[string]*NameCount)
+	provinces := make(map[string]*NameCount)
+	isps := make(map[string]*NameCount)
+	count := func(counts map[string]*NameCount, name string, size *big.Int) {
+		c, ok := counts[name]
+		if !ok {
+			c = &NameCount{Name: name, Addresses: big.NewInt(0)}
+			counts[name] = c
+		}
+		c.Ranges++
+		c.Addresses.Add(c.Addresses, size)
+	}
+	sizes := make(map[int]int)
+	referenced := make([]bool, s.MetaRowCount())
+	// the addresses before the first entity belong to no range
+	if first := s.Entity(0); first == nil || first.IPIndex() > 0 {
+		stats.GapRanges++
+	}
+	for i := 0; i < s.EntityCount(); i++ {
+		index := int(s.Entity(i).MetaRowIndex())
+		if index < len(referenced) {
+			referenced[index] = true
+		}
+		meta := s.Meta(index)
+		if meta.IsEmpty() {
+			stats.GapRanges++
+			continue
+		}
+		size := s.EntityRange(i).Size()
+		stats.Ranges++
+		stats.Covered.Add(stats.Covered, size)
+		count(countries, meta.Country(), size)
+		count(provinces, meta.Province(), size)
+		count(isps, meta.ISP(), size)
+		sizes[bitLen-size.BitLen()+1]++
+	}
+	stats.Gaps.Sub(stats.Space, stats.Covered)
+
+	for i, ok := range referenced {
+		if !ok && !s.Meta(i).IsEmpty() {
+			stats.Unreferenced = append(stats.Unreferenced, i)
+		}
+	}
+	for bits, n := range sizes {
+		stats.Sizes = append(stats.Sizes, SizeCount{Bits: bits, Ranges: n})
+	}
+	sort.Slice(stats.Sizes, func(i, j int) bool { return stats.Sizes[i].Bits < stats.Sizes[j].Bits })
+	stats.Countries, stats.Provinces, stats.ISPs = sortNameCounts(countries), sortNameCounts(provinces), sortNameCounts(isps)
+	return stats
+}
+
+func sortNameCounts(counts map[string]*NameCount) []NameCount {
+	sorted := make([]NameCount, 0, len(counts))
+	for _, c := range counts {
+		sorted = append(sorted, *c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].Addresses.Cmp(sorted[j].Addresses); c != 0 {
+			return c > 0
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
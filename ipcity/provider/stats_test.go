@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestStoreStats(t *testing.T) {
+	store := NewStore().
+		WithHeader(NewHeader(DataVersionLatest, DataModeIPv4)).
+		WithMetaTable([]*Meta{
+			NewMeta(),
+			NewMeta().WithCountry("中国").WithProvince("广东").WithCity("广州").WithISP("电信"),
+			NewMeta().WithCountry("美国").WithCountryCode(1),
+			NewMeta().WithCountry("中国").WithProvince("广东").WithCity("深圳"),
+		}).
+		WithEntityList([]*Entity{
+			NewEntity(0x01000000, 1),
+			NewEntity(0x01800000, 1),
+			NewEntity(0x02000000, 2),
+			NewEntity(0x02000100, 0),
+		})
+	stats := store.Stats()
+	if stats.Mode != "IPv4" || stats.Ranges != 3 || stats.Covered.Int64() != 0x1000100 || stats.GapRanges != 2 ||
+		stats.Gaps.Int64() != 0x100000000-0x1000100 || stats.Space.Int64() != 0x100000000 {
+		t.Errorf("unexpected coverage %+v", stats)
+	}
+	if got := fmt.Sprint(stats.Countries, stats.Provinces, stats.ISPs); got != "[{中国 2 16777216} {美国 1 256}] "+
+		"[{广东 2 16777216} { 1 256}] [{电信 2 16777216} { 1 256}]" {
+		t.Errorf("unexpected name counts %s", got)
+	}
+	if got := fmt.Sprint(stats.Sizes, stats.Unreferenced); got != "[{9 2} {24 1}] [3]" {
+		t.Errorf("unexpected sizes and unreferenced rows %s", got)
+	}
+
+	ipv6 := newTestIPv6Store()
+	stats = ipv6.Stats()
+	if stats.Ranges != 1 || stats.Covered.Cmp(new(big.Int).Lsh(big.NewInt(1), 120)) != 0 || len(stats.Unreferenced) != 0 {
+		t.Errorf("unexpected IPv6 stats %+v", stats)
+	}
+	if stats = NewStore().Stats(); stats.Ranges != 0 || stats.Space.Sign() != 0 {
+		t.Errorf("unexpected stats of an empty store %+v", stats)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"sort"
 )
 
@@ -121,42 +122,83 @@ func (s *Store) EntityCount() int {
 	return 0
 }
 
+// searchEntity returns the index of the entity whose range contains ipIndex, or -1 before the first entity.
+// The last entity covers the addresses up to the end of the space as EntityRange does.
+func (s *Store) searchEntity(ipIndex uint64) int {
+	// the entity list is sorted by the first ip index of each range
+	return sort.Search(s.EntityCount(), func(i int) bool {
+		return s.entityList[i].IPIndex() > ipIndex
+	}) - 1
+}
+
 func (s *Store) searchByIPIndex(ipIndex uint64) *Meta {
-	if index := sort.Search(s.EntityCount(), func(i int) bool {
-		return s.Entity(i).IPIndex() >= ipIndex
-	}); s.Entity(index) != nil {
-		if s.Entity(index).IPIndex() != ipIndex {
-			index = index - 1
-		}
-		return s.Meta(int(s.Entity(index).MetaRowIndex()))
+	if entity := s.Entity(s.searchEntity(ipIndex)); entity != nil {
+		return s.Meta(int(entity.MetaRowIndex()))
 	}
 	return nil
 }
 
-// Search returns the meta queryed from the store.
-func (s *Store) Search(addr net.IP) *Meta {
-	ipIndex := uint64(0)
-	switch s.Header().Mode() {
-	case DataModeIPv4:
-		if b := []byte(addr.To4()); b != nil {
-			if s.Header().Version() == DataVersion(2) {
-				ipIndex = uint64(binary.BigEndian.Uint32(b) >> 8)
-			} else {
-				ipIndex = uint64(binary.BigEndian.Uint32(b))
-			}
+// ipIndex returns the ip index of the address, false if the address does not fit the store mode.
+func (s *Store) ipIndex(addr netip.Addr) (uint64, bool) {
+	switch s.Header().AddrBitLen() {
+	case 32:
+		if addr = addr.Unmap(); !addr.Is4() {
+			return 0, false
 		}
-	case DataModeIPv6:
-		if b := []byte(addr.To16()); b != nil {
-			ipIndex = binary.BigEndian.Uint64(b[0:8])
+		b := addr.As4()
+		if s.Header().Version() == DataVersion(2) {
+			return uint64(binary.BigEndian.Uint32(b[:]) >> 8), true
 		}
+		return uint64(binary.BigEndian.Uint32(b[:])), true
+	case 128:
+		if !addr.IsValid() {
+			return 0, false
+		}
+		b := addr.As16()
+		return binary.BigEndian.Uint64(b[0:8]), true
 	default:
-		// pass
+		return 0, false
+	}
+}
+
+// SearchAddr returns the meta queryed from the store without allocating,
+// nil before the first entity.
+func (s *Store) SearchAddr(addr netip.Addr) *Meta {
+	if ipIndex, ok := s.ipIndex(addr); ok {
+		return s.searchByIPIndex(ipIndex)
 	}
-	return s.searchByIPIndex(ipIndex)
+	return nil
+}
+
+// Search returns the meta queryed from the store.
+func (s *Store) Search(addr net.IP) *Meta {
+	ip, _ := netip.AddrFromSlice(addr)
+	return s.SearchAddr(ip)
 }
 
 // UnmarshalFrom will unmarshal Store from a raeder.
 func (s *Store) UnmarshalFrom(reader io.Reader) error {
+	return (&StoreUnmarshaler{}).UnmarshalFrom(reader, s)
+}
+
+// StoreUnmarshaler defines a unmarshaler for the store.
+type StoreUnmarshaler struct {
+	// Strict rejects the malformed meta rows, see MetaUnmarshaler, the entities
+	// out of order and the entities pointing to no meta row
+	Strict bool
+}
+
+// UnmarshalFrom will unmarshal Store from a reader, the meta row errors are *MetaError.
+func (u *StoreUnmarshaler) UnmarshalFrom(reader io.Reader, s *Store) error {
+	if u == nil {
+		return newNilParamError("StoreUnmarshaler")
+	}
+	if s == nil {
+		return newNilParamError("Store")
+	}
+	if reader == nil {
+		return newNilParamError("Reader")
+	}
 	ireader := bufio.NewReader(reader)
 	err := goUntilError(func() error {
 		header := &Header{impl: &headerImpl{}}
@@ -167,21 +209,25 @@ func (s *Store) UnmarshalFrom(reader io.Reader) error {
 		return nil
 	}, func() error {
 		var err error
+		unmarshaler := &MetaUnmarshaler{DataVersion: s.Header().Version(), Strict: u.Strict}
 		metaTable := make([]*Meta, 0, s.Header().MetaRowCount())
 		var i int
 		for i = 0; i < int(s.Header().MetaRowCount()); i++ {
-			var line []byte
-			if line, err = ireader.ReadBytes('\n'); err != nil {
+			var line string
+			if line, err = ireader.ReadString('\n'); err != nil {
 				break
 			}
-			meta := &Meta{}
-			if err = meta.Unmarshal(line); err != nil {
+			meta := &Meta{schema: s.Header().Schema()}
+			if err = unmarshaler.UnmarshalString(line, meta); err != nil {
+				if e, ok := err.(*MetaError); ok {
+					e.Row = i
+				}
 				break
 			}
 			metaTable = append(metaTable, meta)
 		}
 		if err != nil {
-			return fmt.Errorf("unmarshal meta table row[%d/%d] error, %s",
+			return fmt.Errorf("unmarshal meta table row[%d/%d] error, %w",
 				i, s.Header().MetaRowCount(), err)
 		}
 		s.metaTable = metaTable
@@ -212,6 +258,11 @@ func (s *Store) UnmarshalFrom(reader io.Reader) error {
 			if err = unmarshaler.UnmarshalFrom(ireader, entity); err != nil {
 				break
 			}
+			if u.Strict {
+				if err = s.validateEntity(len(entityList), entity, entityList); err != nil {
+					return err
+				}
+			}
 			entityList = append(entityList, entity)
 		}
 		if err != nil && err != io.EOF {
@@ -231,7 +282,72 @@ func (s *Store) UnmarshalFrom(reader io.Reader) error {
 	return err
 }
 
-// MarshalTo will marshal Store to a writer.
+// validateEntity checks the entity points to a meta row and follows the previous entity.
+func (s *Store) validateEntity(i int, entity *Entity, entityList []*Entity) error {
+	if int(entity.MetaRowIndex()) >= s.MetaRowCount() {
+		return fmt.Errorf("entity[%d] points to meta row[%d], meta table size is %d",
+			i, entity.MetaRowIndex(), s.MetaRowCount())
+	}
+	if i > 0 && entity.IPIndex() <= entityList[i-1].IPIndex() {
+		return fmt.Errorf("entity[%d] ip index %X is not greater than the previous %X",
+			i, entity.IPIndex(), entityList[i-1].IPIndex())
+	}
+	return nil
+}
+
+// MarshalTo will marshal Store to a writer, the counts of the header are set
+// by the meta table and the entity list.
 func (s *Store) MarshalTo(writer io.Writer) (int, error) {
-	return 0, fmt.Errorf("unsupported interface! %s", writer)
+	if s == nil || s.Header() == nil || s.Header().impl == nil {
+		return 0, newNilParamError("Store")
+	}
+	if writer == nil {
+		return 0, newNilParamError("Writer")
+	}
+	impl := *s.Header().impl
+	impl.MetaRowCount = uint32(s.MetaRowCount())
+	impl.EntityCount = uint32(s.EntityCount())
+	header := &Header{impl: &impl}
+
+	iwriter := bufio.NewWriter(writer)
+	var n int
+	err := goUntilError(func() error {
+		m, err := header.MarshalTo(iwriter)
+		n += m
+		if err != nil {
+			return fmt.Errorf("marshal header error, %s", err)
+		}
+		return nil
+	}, func() error {
+		schema := header.Schema()
+		marshaler := &MetaMarshaler{DataVersion: header.Version()}
+		for i, meta := range s.metaTable {
+			row := Meta{}
+			if meta != nil {
+				row = *meta
+			}
+			row.schema = schema
+			m, err := marshaler.MarshalTo(&row, iwriter)
+			n += m
+			if err != nil {
+				return fmt.Errorf("marshal meta table row[%d/%d] error, %s", i, s.MetaRowCount(), err)
+			}
+		}
+		return nil
+	}, func() error {
+		marshaler := &EntityMarshaler{
+			DataVersion:      header.Version(),
+			IPIndexSize:      header.IPIndexSize(),
+			MetaRowIndexSize: header.MetaRowIndexSize(),
+		}
+		for i, entity := range s.entityList {
+			m, err := marshaler.MarshalTo(entity, iwriter)
+			n += m
+			if err != nil {
+				return fmt.Errorf("marshal entity list[%d/%d] error, %s", i, s.EntityCount(), err)
+			}
+		}
+		return nil
+	}, iwriter.Flush)
+	return n, err
 }
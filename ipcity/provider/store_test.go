@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestStoreSearchBounds(t *testing.T) {
+	store := newTestStore(DataModeIPv4,
+		[]uint64{0x01000000, 0x02000000},
+		[]*Meta{NewMeta().WithCity("广州"), NewMeta().WithCountry("美国")})
+	for addr, want := range map[string]string{
+		"0.255.255.255":   "",
+		"1.0.0.0":         "广州",
+		"2.0.0.0":         "美国",
+		"255.255.255.255": "美国",
+	} {
+		meta := store.SearchAddr(netip.MustParseAddr(addr))
+		if got := meta.Country() + meta.City(); got != want {
+			t.Errorf("search %s got %q, want %q", addr, got, want)
+		}
+		if got := store.Search(net.ParseIP(addr)); got != meta {
+			t.Errorf("search %s got %s, want %s", addr, got, meta)
+		}
+	}
+	if meta := store.SearchAddr(netip.MustParseAddr("0.0.0.1")); meta != nil {
+		t.Errorf("unexpected meta %s before the first entity", meta)
+	}
+	if _, r := store.SearchRange(netip.MustParseAddr("255.255.255.255")); r.String() != "2.0.0.0-255.255.255.255" {
+		t.Errorf("unexpected last range %s", r)
+	}
+}
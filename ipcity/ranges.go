@@ -0,0 +1,128 @@
+package ipcity
+
+import (
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"net/netip"
+	"sort"
+)
+
+// Filter exports provider.Filter.
+type Filter = provider.Filter
+
+// NewFilter returns a new filter matching all the meta rows.
+func NewFilter() *Filter {
+	return provider.NewFilter()
+}
+
+// ParseFilter parses the "field=value", "field^=value" and "field~=regexp" expressions into a filter.
+func ParseFilter(expressions ...string) (*Filter, error) {
+	return provider.ParseFilter(expressions...)
+}
+
+// RangeList is the minimal prefix lists of the matched ranges per address family.
+type RangeList struct {
+	IPv4 []netip.Prefix
+	IPv6 []netip.Prefix
+}
+
+// Len returns the count of the prefixes.
+func (l RangeList) Len() int {
+	return len(l.IPv4) + len(l.IPv6)
+}
+
+// Prefixes returns the IPv4 prefixes followed by the IPv6 prefixes.
+func (l RangeList) Prefixes() []netip.Prefix {
+	return append(append(make([]netip.Prefix, 0, l.Len()), l.IPv4...), l.IPv6...)
+}
+
+// FindRanges returns the ranges of the default client matching the filter.
+func FindRanges(filter *Filter) RangeList {
+	return defaultClient.FindRanges(filter)
+}
+
+// FindRanges 查询满足过滤条件的所有ip段, 每个地址按与查询相同的本地覆盖, 特殊用途地址及查询策略确定ip信息,
+// 被本地覆盖或优先的ip信息库改为不匹配ip信息的ip段不返回, 按地址族合并为最少的CIDR, 不含ASN信息库
+func (c *Client) FindRanges(filter *Filter) RangeList {
+	set := c.stores.Load()
+	if set == nil {
+		return RangeList{}
+	}
+	var ranges []provider.Range
+	ranges = append(ranges, c.findRanges(set, provider.DataModeIPv4, filter)...)
+	ranges = append(ranges, c.findRanges(set, provider.DataModeIPv6, filter)...)
+	return newRangeList(ranges)
+}
+
+// findRanges 查询地址族中满足过滤条件的ip段, IPv6地址不做内嵌IPv4转换
+func (c *Client) findRanges(set *storeSet, mode provider.DataMode, filter *Filter) []provider.Range {
+	stores := set.byMode[mode]
+	first, last := netip.IPv4Unspecified(), netip.AddrFrom4([4]byte{255, 255, 255, 255})
+	if mode == provider.DataModeIPv6 {
+		first, last = netip.IPv6Unspecified(), netip.AddrFrom16([16]byte{
+			255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255})
+	}
+	if set.overlay.Len() == 0 && !c.special && len(stores) == 1 {
+		return stores[0].Ranges(filter)
+	}
+
+	// 查询结果只在ip信息库ip段, 本地覆盖及特殊用途地址段的边界处变化
+	bounds := []netip.Addr{first}
+	addBounds := func(r provider.Range) {
+		if r.First.BitLen() == first.BitLen() {
+			bounds = append(bounds, r.First, r.Last.Next())
+		}
+	}
+	for _, store := range stores {
+		for i := 0; i < store.EntityCount(); i++ {
+			addBounds(store.EntityRange(i))
+		}
+	}
+	if set.overlay != nil {
+		set.overlay.table.each(func(prefix netip.Prefix, _ *Meta) { addBounds(provider.PrefixRange(prefix)) })
+	}
+	if c.special {
+		specialBlocks.each(func(prefix netip.Prefix, _ *SpecialBlock) { addBounds(provider.PrefixRange(prefix)) })
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Less(bounds[j]) })
+
+	var ranges []provider.Range
+	for i, addr := range bounds {
+		// the address after the last one is invalid and sorted first
+		if !addr.IsValid() || i+1 < len(bounds) && bounds[i+1] == addr {
+			continue
+		}
+		r := provider.Range{First: addr, Last: last}
+		if i+1 < len(bounds) {
+			r.Last = bounds[i+1].Prev()
+		}
+		result, ok := set.overlay.search(addr)
+		if !ok {
+			result, ok = c.classify(addr)
+		}
+		if !ok {
+			result = c.resolve(stores, addr)
+		}
+		if !result.Meta.IsEmpty() && filter.Match(result.Meta) {
+			ranges = append(ranges, r)
+		}
+	}
+	return provider.MergeRanges(ranges)
+}
+
+// StoreRanges returns the ranges of the store matching the filter.
+func StoreRanges(store *Store, filter *Filter) RangeList {
+	return newRangeList(store.Ranges(filter))
+}
+
+// newRangeList returns the fewest prefixes covering the ranges per address family.
+func newRangeList(ranges []provider.Range) RangeList {
+	var list RangeList
+	for _, prefix := range provider.RangePrefixes(ranges) {
+		if prefix.Addr().Is4() {
+			list.IPv4 = append(list.IPv4, prefix)
+		} else {
+			list.IPv6 = append(list.IPv6, prefix)
+		}
+	}
+	return list
+}
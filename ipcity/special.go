@@ -0,0 +1,150 @@
+package ipcity
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"github.com/OVINC-CN/IPCity/ipcity/provider"
+	"io"
+	"net/netip"
+)
+
+// Scope is the classification of an address by the IANA special-purpose registries.
+type Scope byte
+
+const (
+	// ScopeUnclassified means the address has not been classified.
+	ScopeUnclassified = Scope(0)
+	// ScopeGlobal means the address is not in any special-purpose block.
+	ScopeGlobal = Scope(1)
+	// ScopeUnspecified means the address is in "this network" or is the unspecified address.
+	ScopeUnspecified = Scope(2)
+	// ScopePrivate means the address is in a RFC 1918 private-use block.
+	ScopePrivate = Scope(3)
+	// ScopeShared means the address is in the CGNAT shared address space, 100.64.0.0/10.
+	ScopeShared = Scope(4)
+	// ScopeLoopback means the address is a loopback address.
+	ScopeLoopback = Scope(5)
+	// ScopeLinkLocal means the address is a link-local address.
+	ScopeLinkLocal = Scope(6)
+	// ScopeMulticast means the address is a multicast address.
+	ScopeMulticast = Scope(7)
+	// ScopeDocumentation means the address is reserved for documentation.
+	ScopeDocumentation = Scope(8)
+	// ScopeBenchmarking means the address is reserved for benchmarking.
+	ScopeBenchmarking = Scope(9)
+	// ScopeUniqueLocal means the address is an IPv6 unique local address.
+	ScopeUniqueLocal = Scope(10)
+	// ScopeProtocol means the address is assigned to a protocol or an anycast service.
+	ScopeProtocol = Scope(11)
+	// ScopeDiscard means the address is in the IPv6 discard-only block.
+	ScopeDiscard = Scope(12)
+	// ScopeBroadcast means the address is the limited broadcast address.
+	ScopeBroadcast = Scope(13)
+	// ScopeReserved means the address is reserved or deprecated.
+	ScopeReserved = Scope(14)
+)
+
+// ScopeName is a mapping for scope name.
+var ScopeName = map[Scope]string{
+	ScopeUnclassified:  "",
+	ScopeGlobal:        "global",
+	ScopeUnspecified:   "unspecified",
+	ScopePrivate:       "private",
+	ScopeShared:        "shared",
+	ScopeLoopback:      "loopback",
+	ScopeLinkLocal:     "link-local",
+	ScopeMulticast:     "multicast",
+	ScopeDocumentation: "documentation",
+	ScopeBenchmarking:  "benchmarking",
+	ScopeUniqueLocal:   "unique-local",
+	ScopeProtocol:      "protocol",
+	ScopeDiscard:       "discard",
+	ScopeBroadcast:     "broadcast",
+	ScopeReserved:      "reserved",
+}
+
+func (s Scope) String() string {
+	return ScopeName[s]
+}
+
+// SpecialBlock defines a block in the special-purpose registries.
+type SpecialBlock struct {
+	Prefix netip.Prefix
+	Scope  Scope
+	Name   string
+	RFC    string
+}
+
+//go:embed special.csv
+var specialData []byte
+
+var specialBlocks = mustParseSpecialBlocks(specialData)
+
+func mustParseSpecialBlocks(data []byte) *prefixTable[*SpecialBlock] {
+	scopes := make(map[string]Scope, len(ScopeName))
+	for scope, name := range ScopeName {
+		scopes[name] = scope
+	}
+
+	table := &prefixTable[*SpecialBlock]{}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return table
+		}
+		if err != nil {
+			panic(fmt.Sprintf("parse special blocks error, %s", err))
+		}
+		if row == 0 {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(record[0])
+		if err != nil {
+			panic(fmt.Sprintf("parse special block %s error, %s", record[0], err))
+		}
+		scope, ok := scopes[record[1]]
+		if !ok {
+			panic(fmt.Sprintf("unknown scope %q of special block %s", record[1], record[0]))
+		}
+		table.add(prefix, &SpecialBlock{Prefix: prefix, Scope: scope, Name: record[2], RFC: record[3]})
+	}
+}
+
+// SpecialBlockOf returns the most specific special-purpose block containing the address.
+func SpecialBlockOf(addr netip.Addr) (*SpecialBlock, bool) {
+	block, _, ok := specialBlocks.search(addr)
+	return block, ok
+}
+
+// Classify returns the scope of the address, ScopeGlobal if it is in no special-purpose block.
+func Classify(addr netip.Addr) Scope {
+	if block, ok := SpecialBlockOf(addr); ok {
+		return block.Scope
+	}
+	return ScopeGlobal
+}
+
+// WithSpecialBlocks 设置是否识别特殊用途地址段, 开启后这些地址返回分类而不查询ip信息库
+func (c *Client) WithSpecialBlocks(enabled bool) *Client {
+	if c != nil {
+		c.special = enabled
+	}
+	return c
+}
+
+// classify 在开启特殊用途地址识别时返回特殊用途地址的查询结果
+func (c *Client) classify(addr netip.Addr) (Result, bool) {
+	if !c.special {
+		return Result{}, false
+	}
+	block, ok := SpecialBlockOf(addr)
+	if !ok {
+		return Result{}, false
+	}
+	return Result{Addr: addr, Range: provider.PrefixRange(block.Prefix), Scope: block.Scope}, true
+}
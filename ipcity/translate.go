@@ -0,0 +1,58 @@
+package ipcity
+
+import "net/netip"
+
+// Translation is the way an IPv4 address is extracted from an IPv6 address.
+type Translation byte
+
+const (
+	// TranslationNone means the address is searched as it is.
+	TranslationNone = Translation(0)
+	// TranslationIPv4Mapped means the address is an IPv4-mapped IPv6 address, ::ffff:a.b.c.d.
+	TranslationIPv4Mapped = Translation(1)
+	// Translation6to4 means the address is a 6to4 address, 2002:AABB:CCDD::/48.
+	Translation6to4 = Translation(2)
+	// TranslationTeredo means the address is a Teredo address carrying the obfuscated client IPv4.
+	TranslationTeredo = Translation(3)
+	// TranslationNAT64 means the address is in the NAT64 well-known prefix, 64:ff9b::/96.
+	TranslationNAT64 = Translation(4)
+)
+
+// TranslationName is a mapping for translation name.
+var TranslationName = map[Translation]string{
+	TranslationNone:       "",
+	TranslationIPv4Mapped: "ipv4-mapped",
+	Translation6to4:       "6to4",
+	TranslationTeredo:     "teredo",
+	TranslationNAT64:      "nat64",
+}
+
+func (t Translation) String() string {
+	return TranslationName[t]
+}
+
+var (
+	prefix6to4   = netip.MustParsePrefix("2002::/16")
+	prefixTeredo = netip.MustParsePrefix("2001::/32")
+	prefixNAT64  = netip.MustParsePrefix("64:ff9b::/96")
+)
+
+// EmbeddedIPv4 returns the IPv4 address carried inside an IPv6 address and the translation applied.
+func EmbeddedIPv4(addr netip.Addr) (netip.Addr, Translation) {
+	if !addr.Is6() {
+		return addr, TranslationNone
+	}
+	b := addr.As16()
+	switch {
+	case addr.Is4In6():
+		return addr.Unmap(), TranslationIPv4Mapped
+	case prefix6to4.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), Translation6to4
+	case prefixTeredo.Contains(addr):
+		return netip.AddrFrom4([4]byte{^b[12], ^b[13], ^b[14], ^b[15]}), TranslationTeredo
+	case prefixNAT64.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), TranslationNAT64
+	default:
+		return addr, TranslationNone
+	}
+}
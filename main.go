@@ -1,9 +1,15 @@
 package main
 
 import (
+	"github.com/OVINC-CN/IPCity/cli"
 	"github.com/OVINC-CN/IPCity/engine"
+	"os"
 )
 
 func main() {
+	// run the subcommand if any, otherwise serve
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
 	engine.InitEngine()
 }
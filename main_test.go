@@ -15,6 +15,7 @@ func BenchmarkIPCityV4(b *testing.B) {
 		ipList[i] = randomIPV4()
 	}
 	engine.InitIPCity()
+	b.ReportAllocs()
 	var index int64 = 0
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
@@ -31,6 +32,7 @@ func BenchmarkIPCityV6(b *testing.B) {
 		ipList[i] = randomIPV6()
 	}
 	engine.InitIPCity()
+	b.ReportAllocs()
 	var index int64 = 0
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {